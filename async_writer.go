@@ -0,0 +1,253 @@
+package ratgo
+
+import (
+	"sync"
+	"time"
+)
+
+// AsyncWriterOptions bound the batches an AsyncWriter commits.
+type AsyncWriterOptions struct {
+	// MaxBatchCount is the maximum number of writes in a batch. It defaults
+	// to 1024.
+	MaxBatchCount int
+	// MaxBatchBytes is the size of the keys and values in a batch after
+	// which no more writes are added to it. It defaults to 1 MiB.
+	MaxBatchBytes int
+	// MaxDelay is how long a batch waits for more writes before it is
+	// committed. By default, a batch is committed as soon as the previous
+	// one is, so writes are only coalesced while a commit is in progress
+	// and no latency is added.
+	MaxDelay time.Duration
+}
+
+// AsyncWriter coalesces the writes of many goroutines into WriteBatches
+// that are written with DB.Write one after the other, so that they share
+// the cost of a commit. With WriteOptions.SetSync(true), this saves an
+// fsync per write.
+//
+// The writes return a WriteFuture that reports when their batch is
+// committed. The writes of a batch are committed atomically, in the order
+// they were made, and batches are committed in the order they were filled.
+//
+// An AsyncWriter is safe for concurrent use. To prevent memory leaks and
+// lost writes, Close must be called on it when it is no longer needed, and
+// before the DB is closed.
+type AsyncWriter struct {
+	db   *DB
+	wo   *WriteOptions
+	opts AsyncWriterOptions
+
+	stopped chan struct{}
+
+	mu sync.Mutex
+	// cond is signaled when queue changes or the AsyncWriter is closed.
+	cond sync.Cond
+	// queue are the groups that the committer didn't start on yet. It holds
+	// at most one group, so that one group can fill up while the previous
+	// one is committed; after that, writes wait.
+	queue []*writeGroup
+	// cur is the group that writes are added to, if there is one, and last
+	// the group that was opened last.
+	cur  *writeGroup
+	last *writeGroup
+	// err is the first error of the commits since the last Flush.
+	err    error
+	closed bool
+}
+
+// writeGroup is a batch of writes that are committed together.
+type writeGroup struct {
+	batch *WriteBatch
+	count int
+	bytes int
+	// full is closed when no more writes may be added to the group.
+	full chan struct{}
+	// done is closed when the group is committed, and err is then the
+	// error of the commit.
+	done chan struct{}
+	err  error
+}
+
+// WriteFuture is the result of a write of an AsyncWriter.
+type WriteFuture struct {
+	g *writeGroup
+}
+
+// Done returns a channel that is closed when the write is committed or
+// failed.
+func (f *WriteFuture) Done() <-chan struct{} {
+	return f.g.done
+}
+
+// Wait waits until the write is committed, and returns the error of
+// DB.Write if it failed.
+func (f *WriteFuture) Wait() error {
+	<-f.g.done
+	return f.g.err
+}
+
+// NewAsyncWriter returns an AsyncWriter that writes to db with wo, which
+// must not be closed before the AsyncWriter is.
+func NewAsyncWriter(db *DB, wo *WriteOptions, opts AsyncWriterOptions) *AsyncWriter {
+	if opts.MaxBatchCount <= 0 {
+		opts.MaxBatchCount = 1024
+	}
+	if opts.MaxBatchBytes <= 0 {
+		opts.MaxBatchBytes = 1 << 20
+	}
+	w := &AsyncWriter{
+		db:      db,
+		wo:      wo,
+		opts:    opts,
+		stopped: make(chan struct{}),
+	}
+	w.cond.L = &w.mu
+	go w.run()
+	return w
+}
+
+// Put writes data associated with a key when its batch is committed. The
+// key and value may be reused safely.
+func (w *AsyncWriter) Put(key, value []byte) *WriteFuture {
+	return w.add(len(key)+len(value), func(b *WriteBatch) { b.Put(key, value) })
+}
+
+// Delete removes the data associated with the key when its batch is
+// committed.
+func (w *AsyncWriter) Delete(key []byte) *WriteFuture {
+	return w.add(len(key), func(b *WriteBatch) { b.Delete(key) })
+}
+
+// Merge merges value into the data associated with the key when its batch
+// is committed.
+func (w *AsyncWriter) Merge(key, value []byte) *WriteFuture {
+	return w.add(len(key)+len(value), func(b *WriteBatch) { b.Merge(key, value) })
+}
+
+// add adds a write of size bytes to the current group, opening one if there
+// is none.
+func (w *AsyncWriter) add(size int, write func(b *WriteBatch)) *WriteFuture {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	// Wait while a group is committed and the next one is full.
+	for !w.closed && w.cur == nil && len(w.queue) != 0 {
+		w.cond.Wait()
+	}
+	if w.closed {
+		done := make(chan struct{})
+		close(done)
+		return &WriteFuture{&writeGroup{done: done, err: ErrClosed}}
+	}
+	g := w.cur
+	if g == nil {
+		g = &writeGroup{
+			batch: NewWriteBatch(),
+			full:  make(chan struct{}),
+			done:  make(chan struct{}),
+		}
+		w.queue = append(w.queue, g)
+		w.cur, w.last = g, g
+		w.cond.Broadcast()
+	}
+	write(g.batch)
+	g.count++
+	g.bytes += size
+	if g.count >= w.opts.MaxBatchCount || g.bytes >= w.opts.MaxBatchBytes {
+		w.closeGroup()
+	}
+	return &WriteFuture{g}
+}
+
+// closeGroup stops adding writes to the current group, so that it is
+// committed right away. w.mu must be held.
+func (w *AsyncWriter) closeGroup() {
+	if w.cur != nil {
+		close(w.cur.full)
+		w.cur = nil
+	}
+}
+
+// run commits the groups one after the other, until the AsyncWriter is
+// closed and the queue is empty.
+func (w *AsyncWriter) run() {
+	defer close(w.stopped)
+	for {
+		w.mu.Lock()
+		for !w.closed && len(w.queue) == 0 {
+			w.cond.Wait()
+		}
+		if len(w.queue) == 0 {
+			w.mu.Unlock()
+			return
+		}
+		g := w.queue[0]
+		w.queue = w.queue[1:]
+		w.cond.Broadcast()
+		w.mu.Unlock()
+
+		if w.opts.MaxDelay > 0 {
+			timer := time.NewTimer(w.opts.MaxDelay)
+			select {
+			case <-g.full:
+			case <-timer.C:
+			}
+			timer.Stop()
+		}
+
+		w.mu.Lock()
+		if w.cur == g {
+			w.cur = nil
+		}
+		w.mu.Unlock()
+
+		g.err = w.db.Write(w.wo, g.batch)
+		g.batch.Close()
+		if g.err != nil {
+			w.mu.Lock()
+			if w.err == nil {
+				w.err = g.err
+			}
+			w.mu.Unlock()
+		}
+		close(g.done)
+	}
+}
+
+// Flush commits the writes made so far without waiting for more, waits
+// until they are committed, and returns the first error of the commits
+// since the previous Flush.
+func (w *AsyncWriter) Flush() error {
+	w.mu.Lock()
+	w.closeGroup()
+	g := w.last
+	w.mu.Unlock()
+	if g != nil {
+		<-g.done
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	err := w.err
+	w.err = nil
+	return err
+}
+
+// Close commits the writes made so far and stops the AsyncWriter, like
+// Flush. The writes made after Close fail with ErrClosed. Closing it again
+// does nothing.
+func (w *AsyncWriter) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	w.closeGroup()
+	w.cond.Broadcast()
+	w.mu.Unlock()
+
+	<-w.stopped
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.err
+}
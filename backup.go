@@ -0,0 +1,549 @@
+package ratgo
+
+import (
+	"bufio"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// BackupError is returned for the errors a BackupEngine finds itself, such
+// as a backup that doesn't exist or a backup file that doesn't match its
+// checksum.
+type BackupError string
+
+func (e BackupError) Error() string {
+	return string(e)
+}
+
+// BackupEngine manages incremental backups of databases in a directory.
+//
+// Table files never change once written, so they are shared between the
+// backups and only copied once. They are named after their checksum and size
+// as well, so that table files with the same name from different databases
+// are kept apart. Everything else, like the MANIFEST and the write-ahead
+// logs, is copied into every backup. A backup directory is laid out as
+//
+//	meta/<id>                  the files of the backup with their sizes and checksums
+//	private/<id>/              the files that belong to this backup only
+//	shared/<name>_<crc>_<size> the table files of all the backups
+//
+// A backup only becomes visible once its meta file is written, so a backup
+// that failed halfway leaves nothing but garbage behind, which is cleaned up
+// by the next OpenBackupEngine.
+//
+// A BackupEngine may be shared between goroutines, but a backup directory
+// must not be used by more than one BackupEngine at a time. The methods
+// called after Close return ErrClosed.
+type BackupEngine struct {
+	dir string
+
+	mu      sync.Mutex
+	backups map[uint32]*backupMeta
+	latest  uint32
+	// closed is set by Close.
+	closed bool
+}
+
+// BackupInfo describes a backup.
+type BackupInfo struct {
+	ID uint32
+	// Timestamp is the time the backup was created, in seconds since the
+	// Unix epoch.
+	Timestamp int64
+	// Size is the total size of the files of the backup, including the
+	// ones it shares with other backups.
+	Size     uint64
+	NumFiles uint32
+}
+
+// backupMeta is the content of a meta file.
+type backupMeta struct {
+	info  BackupInfo
+	files []backupFile
+}
+
+// backupFile is a file of a backup.
+type backupFile struct {
+	// path is the path of the file relative to the backup directory.
+	path string
+	size int64
+	crc  uint32
+}
+
+// OpenBackupEngine opens the backup directory dir, creating it if it doesn't
+// exist.
+func OpenBackupEngine(dir string) (*BackupEngine, error) {
+	for _, sub := range []string{"meta", "private", "shared"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0755); err != nil {
+			return nil, err
+		}
+	}
+	be := &BackupEngine{dir: dir, backups: make(map[uint32]*backupMeta)}
+
+	entries, err := os.ReadDir(filepath.Join(dir, "meta"))
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		id, err := strconv.ParseUint(entry.Name(), 10, 32)
+		if err != nil {
+			// Left behind by a backup that failed before it was complete.
+			os.Remove(filepath.Join(dir, "meta", entry.Name()))
+			continue
+		}
+		meta, err := be.readMeta(uint32(id))
+		if err != nil {
+			return nil, err
+		}
+		be.backups[meta.info.ID] = meta
+		if meta.info.ID > be.latest {
+			be.latest = meta.info.ID
+		}
+	}
+	if err := be.garbageCollect(); err != nil {
+		return nil, err
+	}
+	return be, nil
+}
+
+// Close releases the BackupEngine. The backups are left untouched. Closing it
+// again does nothing.
+func (be *BackupEngine) Close() {
+	be.mu.Lock()
+	defer be.mu.Unlock()
+	be.backups = nil
+	be.closed = true
+}
+
+// check returns ErrClosed if the BackupEngine is closed. be.mu must be held.
+func (be *BackupEngine) check() error {
+	if be.closed {
+		return ErrClosed
+	}
+	return nil
+}
+
+// CreateNewBackup backs up the current state of db. If flushBeforeBackup is
+// true, the memtable is flushed first and the write-ahead logs are left out
+// of the backup, otherwise they are copied so that the unflushed writes are
+// backed up too.
+//
+// Writes to db may continue while the backup is created.
+func (be *BackupEngine) CreateNewBackup(db *DB, flushBeforeBackup bool) error {
+	be.mu.Lock()
+	defer be.mu.Unlock()
+	if err := be.check(); err != nil {
+		return err
+	}
+
+	id := be.latest + 1
+	privateDir := filepath.Join(be.dir, "private", strconv.FormatUint(uint64(id), 10))
+	tmpDir := privateDir + ".tmp"
+	if err := os.RemoveAll(tmpDir); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(tmpDir, 0755); err != nil {
+		return err
+	}
+
+	meta := &backupMeta{info: BackupInfo{ID: id, Timestamp: time.Now().Unix()}}
+	err := db.withLiveFiles(flushBeforeBackup, func(files []liveFile, manifest string) error {
+		for _, file := range files {
+			f, err := be.backupFile(db.name, id, tmpDir, file)
+			if err != nil {
+				return err
+			}
+			meta.files = append(meta.files, f)
+		}
+		current := []byte(manifest + "\n")
+		if err := writeFileSync(filepath.Join(tmpDir, "CURRENT"), current); err != nil {
+			return err
+		}
+		meta.files = append(meta.files, backupFile{
+			path: filepath.Join("private", filepath.Base(privateDir), "CURRENT"),
+			size: int64(len(current)),
+			crc:  crc32.ChecksumIEEE(current),
+		})
+		return nil
+	})
+	if err == nil {
+		err = syncDir(tmpDir)
+	}
+	if err == nil {
+		err = os.Rename(tmpDir, privateDir)
+	}
+	if err == nil {
+		err = be.writeMeta(meta)
+	}
+	if err != nil {
+		os.RemoveAll(tmpDir)
+		os.RemoveAll(privateDir)
+		return err
+	}
+
+	for _, f := range meta.files {
+		meta.info.Size += uint64(f.size)
+	}
+	meta.info.NumFiles = uint32(len(meta.files))
+	be.backups[id] = meta
+	be.latest = id
+	return nil
+}
+
+// backupFile copies file from the database directory dbDir into the backup
+// id, whose private files are written to privateDir. Table files that are
+// already in the backup directory with the same checksum and size are not
+// copied again.
+func (be *BackupEngine) backupFile(dbDir string, id uint32, privateDir string, file liveFile) (backupFile, error) {
+	src := filepath.Join(dbDir, file.name)
+	if file.size >= 0 {
+		crc, err := copyFile(src, filepath.Join(privateDir, file.name), file.size)
+		path := filepath.Join("private", strconv.FormatUint(uint64(id), 10), file.name)
+		return backupFile{path, file.size, crc}, err
+	}
+
+	crc, size, err := checksumFile(src)
+	if err != nil {
+		return backupFile{}, err
+	}
+	path := sharedPath(file.name, crc, size)
+	if f, ok := be.sharedFile(path); ok {
+		return f, nil
+	}
+	dst := filepath.Join(be.dir, path)
+	os.Remove(dst + ".tmp")
+	copied, err := copyFile(src, dst+".tmp", -1)
+	if err == nil && copied != crc {
+		err = BackupError(fmt.Sprintf("ratgo: %s changed while it was backed up", src))
+	}
+	if err == nil {
+		err = os.Rename(dst+".tmp", dst)
+	}
+	if err == nil {
+		err = syncDir(filepath.Dir(dst))
+	}
+	if err != nil {
+		os.Remove(dst + ".tmp")
+	}
+	return backupFile{path, size, crc}, err
+}
+
+// sharedPath returns the path in the backup directory of the table file name
+// with the given checksum and size.
+func sharedPath(name string, crc uint32, size int64) string {
+	return filepath.Join("shared", fmt.Sprintf("%s_%08x_%d", name, crc, size))
+}
+
+// dbFileName returns the name in the database directory of the backup file
+// at path, which is its base name without the checksum and size for shared
+// files.
+func dbFileName(path string) string {
+	name := filepath.Base(path)
+	if filepath.Dir(path) != "shared" {
+		return name
+	}
+	for i := 0; i < 2; i++ {
+		if j := strings.LastIndexByte(name, '_'); j >= 0 {
+			name = name[:j]
+		}
+	}
+	return name
+}
+
+// sharedFile returns the file at path in the backup directory if one of the
+// backups refers to it.
+func (be *BackupEngine) sharedFile(path string) (backupFile, bool) {
+	for _, meta := range be.backups {
+		for _, f := range meta.files {
+			if f.path == path {
+				return f, true
+			}
+		}
+	}
+	return backupFile{}, false
+}
+
+// GetBackupInfo returns the backups in the backup directory, oldest first.
+func (be *BackupEngine) GetBackupInfo() ([]BackupInfo, error) {
+	be.mu.Lock()
+	defer be.mu.Unlock()
+	if err := be.check(); err != nil {
+		return nil, err
+	}
+
+	infos := make([]BackupInfo, 0, len(be.backups))
+	for _, meta := range be.backups {
+		infos = append(infos, meta.info)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].ID < infos[j].ID })
+	return infos, nil
+}
+
+// VerifyBackup checks that all the files of a backup exist and have the size
+// and checksum they had when they were backed up.
+func (be *BackupEngine) VerifyBackup(id uint32) error {
+	be.mu.Lock()
+	defer be.mu.Unlock()
+	if err := be.check(); err != nil {
+		return err
+	}
+
+	meta, err := be.backup(id)
+	if err != nil {
+		return err
+	}
+	for _, f := range meta.files {
+		if err := be.verifyFile(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (be *BackupEngine) verifyFile(f backupFile) error {
+	crc, size, err := checksumFile(filepath.Join(be.dir, f.path))
+	if err != nil {
+		return err
+	}
+	if size != f.size {
+		return BackupError(fmt.Sprintf("ratgo: backup file %s has size %d, expected %d", f.path, size, f.size))
+	}
+	if crc != f.crc {
+		return BackupError(fmt.Sprintf("ratgo: backup file %s has checksum %08x, expected %08x", f.path, crc, f.crc))
+	}
+	return nil
+}
+
+// checksumFile returns the CRC-32 checksum and the size of a file.
+func checksumFile(name string) (uint32, int64, error) {
+	in, err := os.Open(name)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer in.Close()
+
+	crc := crc32.NewIEEE()
+	n, err := io.Copy(crc, in)
+	return crc.Sum32(), n, err
+}
+
+// PurgeOldBackups deletes all but the keep most recent backups.
+func (be *BackupEngine) PurgeOldBackups(keep int) error {
+	be.mu.Lock()
+	defer be.mu.Unlock()
+	if err := be.check(); err != nil {
+		return err
+	}
+
+	ids := make([]uint32, 0, len(be.backups))
+	for id := range be.backups {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	for len(ids) > keep && len(ids) > 0 {
+		if err := be.deleteBackup(ids[0]); err != nil {
+			return err
+		}
+		ids = ids[1:]
+	}
+	return be.garbageCollect()
+}
+
+// DeleteBackup deletes a backup. The table files it shares with other
+// backups are kept.
+func (be *BackupEngine) DeleteBackup(id uint32) error {
+	be.mu.Lock()
+	defer be.mu.Unlock()
+	if err := be.check(); err != nil {
+		return err
+	}
+
+	if _, err := be.backup(id); err != nil {
+		return err
+	}
+	if err := be.deleteBackup(id); err != nil {
+		return err
+	}
+	return be.garbageCollect()
+}
+
+func (be *BackupEngine) deleteBackup(id uint32) error {
+	// Once the meta file is gone the backup is, so the rest can be left to
+	// garbageCollect if removing it fails.
+	if err := os.Remove(be.metaPath(id)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	delete(be.backups, id)
+	return nil
+}
+
+// garbageCollect removes the files in the backup directory that don't belong
+// to any backup.
+func (be *BackupEngine) garbageCollect() error {
+	live := make(map[string]bool)
+	for _, meta := range be.backups {
+		for _, f := range meta.files {
+			live[f.path] = true
+		}
+	}
+
+	shared, err := os.ReadDir(filepath.Join(be.dir, "shared"))
+	if err != nil {
+		return err
+	}
+	for _, entry := range shared {
+		path := filepath.Join("shared", entry.Name())
+		if !live[path] {
+			if err := os.RemoveAll(filepath.Join(be.dir, path)); err != nil {
+				return err
+			}
+		}
+	}
+
+	private, err := os.ReadDir(filepath.Join(be.dir, "private"))
+	if err != nil {
+		return err
+	}
+	for _, entry := range private {
+		id, err := strconv.ParseUint(entry.Name(), 10, 32)
+		if err == nil && be.backups[uint32(id)] != nil {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(be.dir, "private", entry.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RestoreDBFromLatestBackup restores the most recent backup into dbDir.
+//
+// See RestoreDBFromBackup for details.
+func (be *BackupEngine) RestoreDBFromLatestBackup(dbDir string) error {
+	be.mu.Lock()
+	closed := be.closed
+	var latest uint32
+	for id := range be.backups {
+		if id > latest {
+			latest = id
+		}
+	}
+	be.mu.Unlock()
+	if closed {
+		return ErrClosed
+	}
+	if latest == 0 {
+		return BackupError("ratgo: there are no backups to restore")
+	}
+	return be.RestoreDBFromBackup(latest, dbDir)
+}
+
+// RestoreDBFromBackup restores a backup into dbDir, which must not exist or
+// be empty. The files are checked against their checksums while they are
+// copied; if one doesn't match, an error is returned and dbDir must not be
+// opened.
+func (be *BackupEngine) RestoreDBFromBackup(id uint32, dbDir string) error {
+	be.mu.Lock()
+	defer be.mu.Unlock()
+	if err := be.check(); err != nil {
+		return err
+	}
+
+	meta, err := be.backup(id)
+	if err != nil {
+		return err
+	}
+	entries, err := os.ReadDir(dbDir)
+	if err == nil && len(entries) != 0 {
+		return &os.PathError{Op: "restore", Path: dbDir, Err: os.ErrExist}
+	} else if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := os.MkdirAll(dbDir, 0755); err != nil {
+		return err
+	}
+
+	for _, f := range meta.files {
+		dst := filepath.Join(dbDir, dbFileName(f.path))
+		crc, err := copyFile(filepath.Join(be.dir, f.path), dst, -1)
+		if err != nil {
+			return err
+		}
+		if crc != f.crc {
+			return BackupError(fmt.Sprintf("ratgo: backup file %s has checksum %08x, expected %08x", f.path, crc, f.crc))
+		}
+	}
+	return syncDir(dbDir)
+}
+
+// backup returns the backup with the given id.
+func (be *BackupEngine) backup(id uint32) (*backupMeta, error) {
+	meta, ok := be.backups[id]
+	if !ok {
+		return nil, BackupError(fmt.Sprintf("ratgo: backup %d not found", id))
+	}
+	return meta, nil
+}
+
+func (be *BackupEngine) metaPath(id uint32) string {
+	return filepath.Join(be.dir, "meta", strconv.FormatUint(uint64(id), 10))
+}
+
+// writeMeta writes the meta file of a backup, which makes the backup
+// visible. The file holds the timestamp, the number of files and one
+// "path size crc" line per file.
+func (be *BackupEngine) writeMeta(meta *backupMeta) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d\n%d\n", meta.info.Timestamp, len(meta.files))
+	for _, f := range meta.files {
+		fmt.Fprintf(&b, "%s %d %08x\n", filepath.ToSlash(f.path), f.size, f.crc)
+	}
+
+	name := be.metaPath(meta.info.ID)
+	if err := writeFileSync(name+".tmp", []byte(b.String())); err != nil {
+		return err
+	}
+	if err := os.Rename(name+".tmp", name); err != nil {
+		return err
+	}
+	return syncDir(filepath.Dir(name))
+}
+
+// readMeta reads the meta file of a backup.
+func (be *BackupEngine) readMeta(id uint32) (*backupMeta, error) {
+	name := be.metaPath(id)
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	corrupt := func() (*backupMeta, error) {
+		return nil, BackupError(fmt.Sprintf("ratgo: corrupt backup meta file %s", name))
+	}
+	meta := &backupMeta{info: BackupInfo{ID: id}}
+	var numFiles int
+	r := bufio.NewReader(f)
+	if _, err := fmt.Fscanf(r, "%d\n%d\n", &meta.info.Timestamp, &numFiles); err != nil {
+		return corrupt()
+	}
+	for i := 0; i < numFiles; i++ {
+		var file backupFile
+		if _, err := fmt.Fscanf(r, "%s %d %x\n", &file.path, &file.size, &file.crc); err != nil {
+			return corrupt()
+		}
+		file.path = filepath.FromSlash(file.path)
+		meta.files = append(meta.files, file)
+		meta.info.Size += uint64(file.size)
+	}
+	meta.info.NumFiles = uint32(numFiles)
+	return meta, nil
+}
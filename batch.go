@@ -8,24 +8,35 @@ import (
 	"unsafe"
 )
 
-// WriteBatch is a batching of Puts, and Deletes to be written atomically to a
-// database. A WriteBatch is written when passed to DB.Write.
+// WriteBatch is a batching of Puts, Merges and Deletes to be written
+// atomically to a database. A WriteBatch is written when passed to DB.Write.
+// If a closed ColumnFamilyHandle was passed to it, DB.Write returns ErrClosed
+// instead, until the WriteBatch is cleared.
 //
 // To prevent memory leaks, call Close when the program no longer needs the
 // WriteBatch object.
 type WriteBatch struct {
 	wbatch *C.leveldb_writebatch_t
+	// err is ErrClosed once a closed ColumnFamilyHandle was passed to the
+	// WriteBatch. DB.Write returns it instead of writing the batch.
+	err error
 }
 
 // NewWriteBatch creates a fully allocated WriteBatch.
 func NewWriteBatch() *WriteBatch {
 	wb := C.leveldb_writebatch_create()
-	return &WriteBatch{wb}
+	return &WriteBatch{wbatch: wb}
 }
 
-// Close releases the underlying memory of a WriteBatch.
+// Close releases the underlying memory of a WriteBatch. The methods of a
+// closed WriteBatch do nothing, and DB.Write returns ErrClosed for it.
+// Closing it again does nothing.
 func (w *WriteBatch) Close() {
+	if w.wbatch == nil {
+		return
+	}
 	C.leveldb_writebatch_destroy(w.wbatch)
+	w.wbatch = nil
 }
 
 // Put places a key-value pair into the WriteBatch for writing later.
@@ -34,6 +45,9 @@ func (w *WriteBatch) Close() {
 // of them before returning.
 //
 func (w *WriteBatch) Put(key, value []byte) {
+	if w.wbatch == nil {
+		return
+	}
 	// leveldb_writebatch_put, and _delete call memcpy() (by way of
 	// Memtable::Add) when called, so we do not need to worry about these
 	// []byte being reclaimed by GC.
@@ -51,16 +65,112 @@ func (w *WriteBatch) Put(key, value []byte) {
 	C.leveldb_writebatch_put(w.wbatch, k, C.size_t(lenk), v, C.size_t(lenv))
 }
 
+// Merge queues a merge of value into the data at key, using the database's
+// MergeOperator, for writing later.
+//
+// Both the key and value byte slices may be reused as WriteBatch takes a copy
+// of them before returning.
+func (w *WriteBatch) Merge(key, value []byte) {
+	if w.wbatch == nil {
+		return
+	}
+	var k, v *C.char
+	if len(key) != 0 {
+		k = (*C.char)(unsafe.Pointer(&key[0]))
+	}
+	if len(value) != 0 {
+		v = (*C.char)(unsafe.Pointer(&value[0]))
+	}
+
+	C.leveldb_writebatch_merge(w.wbatch, k, C.size_t(len(key)), v, C.size_t(len(value)))
+}
+
 // Delete queues a deletion of the data at key to be deleted later.
 //
 // The key byte slice may be reused safely. Delete takes a copy of
 // them before returning.
 func (w *WriteBatch) Delete(key []byte) {
+	if w.wbatch == nil {
+		return
+	}
 	C.leveldb_writebatch_delete(w.wbatch,
 		(*C.char)(unsafe.Pointer(&key[0])), C.size_t(len(key)))
 }
 
+// PutCF places a key-value pair for a column family into the WriteBatch for
+// writing later.
+//
+// Both the key and value byte slices may be reused as WriteBatch takes a copy
+// of them before returning.
+func (w *WriteBatch) PutCF(cf *ColumnFamilyHandle, key, value []byte) {
+	if w.wbatch == nil {
+		return
+	}
+	if cf.cf == nil {
+		w.err = ErrClosed
+		return
+	}
+	var k, v *C.char
+	if len(key) != 0 {
+		k = (*C.char)(unsafe.Pointer(&key[0]))
+	}
+	if len(value) != 0 {
+		v = (*C.char)(unsafe.Pointer(&value[0]))
+	}
+
+	C.leveldb_writebatch_put_cf(w.wbatch, cf.cf, k, C.size_t(len(key)), v, C.size_t(len(value)))
+}
+
+// MergeCF queues a merge of value into the data at key in a column family
+// for writing later.
+//
+// Both the key and value byte slices may be reused as WriteBatch takes a copy
+// of them before returning.
+func (w *WriteBatch) MergeCF(cf *ColumnFamilyHandle, key, value []byte) {
+	if w.wbatch == nil {
+		return
+	}
+	if cf.cf == nil {
+		w.err = ErrClosed
+		return
+	}
+	var k, v *C.char
+	if len(key) != 0 {
+		k = (*C.char)(unsafe.Pointer(&key[0]))
+	}
+	if len(value) != 0 {
+		v = (*C.char)(unsafe.Pointer(&value[0]))
+	}
+
+	C.leveldb_writebatch_merge_cf(w.wbatch, cf.cf, k, C.size_t(len(key)), v, C.size_t(len(value)))
+}
+
+// DeleteCF queues a deletion of the data at key in a column family to be
+// deleted later.
+//
+// The key byte slice may be reused safely. DeleteCF takes a copy of
+// them before returning.
+func (w *WriteBatch) DeleteCF(cf *ColumnFamilyHandle, key []byte) {
+	if w.wbatch == nil {
+		return
+	}
+	if cf.cf == nil {
+		w.err = ErrClosed
+		return
+	}
+	var k *C.char
+	if len(key) != 0 {
+		k = (*C.char)(unsafe.Pointer(&key[0]))
+	}
+
+	C.leveldb_writebatch_delete_cf(w.wbatch, cf.cf, k, C.size_t(len(key)))
+}
+
 // Clear removes all the enqueued Put and Deletes in the WriteBatch.
 func (w *WriteBatch) Clear() {
+	if w.wbatch == nil {
+		return
+	}
 	C.leveldb_writebatch_clear(w.wbatch)
+	w.err = nil
 }
@@ -14,33 +14,59 @@
 #include <unistd.h>
 #include "rocksdb/cache.h"
 #include "rocksdb/comparator.h"
+#include "rocksdb/compaction_filter.h"
 #include "rocksdb/db.h"
 #include "rocksdb/env.h"
 #include "rocksdb/filter_policy.h"
 #include "rocksdb/iterator.h"
+#include "rocksdb/merge_operator.h"
 #include "rocksdb/options.h"
+#include "rocksdb/slice_transform.h"
 #include "rocksdb/status.h"
+#include "rocksdb/table.h"
 #include "rocksdb/write_batch.h"
+#include "rocksdb/utilities/db_ttl.h"
+#include "rocksdb/utilities/optimistic_transaction_db.h"
+#include "rocksdb/utilities/transaction.h"
+#include "rocksdb/utilities/transaction_db.h"
 
+using rocksdb::BlockBasedTableOptions;
 using rocksdb::Cache;
+using rocksdb::ColumnFamilyDescriptor;
+using rocksdb::ColumnFamilyHandle;
+using rocksdb::ColumnFamilyOptions;
+using rocksdb::CompactRangeOptions;
+using rocksdb::CompactionFilter;
 using rocksdb::Comparator;
 using rocksdb::CompressionType;
 using rocksdb::DB;
+using rocksdb::DBOptions;
+using rocksdb::DBWithTTL;
 using rocksdb::Env;
 using rocksdb::FileLock;
 using rocksdb::FilterPolicy;
 using rocksdb::Iterator;
 using rocksdb::Logger;
+using rocksdb::MergeOperator;
+using rocksdb::NewBlockBasedTableFactory;
 using rocksdb::NewBloomFilterPolicy;
 using rocksdb::NewLRUCache;
+using rocksdb::OptimisticTransactionDB;
+using rocksdb::OptimisticTransactionOptions;
 using rocksdb::Options;
+using rocksdb::PinnableSlice;
 using rocksdb::RandomAccessFile;
 using rocksdb::Range;
 using rocksdb::ReadOptions;
 using rocksdb::SequentialFile;
 using rocksdb::Slice;
+using rocksdb::SliceTransform;
 using rocksdb::Snapshot;
 using rocksdb::Status;
+using rocksdb::Transaction;
+using rocksdb::TransactionDB;
+using rocksdb::TransactionDBOptions;
+using rocksdb::TransactionOptions;
 using rocksdb::WritableFile;
 using rocksdb::WriteBatch;
 using rocksdb::WriteOptions;
@@ -51,12 +77,29 @@ using std::shared_ptr;
 extern "C" {
 
 struct leveldb_t              { DB*               rep; };
-struct leveldb_iterator_t     { Iterator*         rep; };
+struct leveldb_iterator_t {
+  Iterator* rep;
+  // A copy of the read options the iterator was created with, which holds
+  // the bounds the iterator refers to.
+  leveldb_readoptions_t* options;
+};
 struct leveldb_writebatch_t   { WriteBatch        rep; };
 struct leveldb_snapshot_t     { const Snapshot*   rep; };
-struct leveldb_readoptions_t  { ReadOptions       rep; };
+struct leveldb_readoptions_t {
+  ReadOptions rep;
+  // The keys rep.iterate_lower_bound and rep.iterate_upper_bound point to.
+  std::string lower_bound;
+  std::string upper_bound;
+  Slice lower_bound_slice;
+  Slice upper_bound_slice;
+};
 struct leveldb_writeoptions_t { WriteOptions      rep; };
-struct leveldb_options_t      { Options           rep; };
+struct leveldb_options_t {
+  Options rep;
+  // The options of the block-based table factory of rep, which the block,
+  // cache and filter setters change.
+  BlockBasedTableOptions table_options;
+};
 struct leveldb_seqfile_t      { SequentialFile*   rep; };
 struct leveldb_randomfile_t   { RandomAccessFile* rep; };
 struct leveldb_writablefile_t { WritableFile*     rep; };
@@ -64,6 +107,11 @@ struct leveldb_filelock_t     { FileLock*         rep; };
 struct leveldb_logger_t       { shared_ptr<Logger>  rep; };
 struct leveldb_cache_t        { shared_ptr<Cache>   rep; };
 struct leveldb_flushoptions_t { FlushOptions rep;};
+struct leveldb_column_family_handle_t { ColumnFamilyHandle* rep; };
+struct leveldb_transaction_t  { Transaction*      rep; };
+struct leveldb_pinnableslice_t { PinnableSlice    rep; };
+struct leveldb_transactiondb_options_t { TransactionDBOptions rep; };
+struct leveldb_transaction_options_t { TransactionOptions rep; };
 
 struct leveldb_comparator_t : public Comparator {
   void* state_;
@@ -132,21 +180,189 @@ struct leveldb_filterpolicy_t : public FilterPolicy {
   }
 };
 
+struct leveldb_mergeoperator_t : public MergeOperator {
+  void* state_;
+  void (*destructor_)(void*);
+  const char* (*name_)(void*);
+  char* (*full_merge_)(
+      void*,
+      const char* key, size_t key_length,
+      const char* existing_value, size_t existing_value_length,
+      const char* const* operands_list, const size_t* operands_list_length,
+      int num_operands,
+      unsigned char* success, size_t* new_value_length);
+  char* (*partial_merge_)(
+      void*,
+      const char* key, size_t key_length,
+      const char* left_operand, size_t left_operand_length,
+      const char* right_operand, size_t right_operand_length,
+      unsigned char* success, size_t* new_value_length);
+  void (*delete_value_)(
+      void*,
+      const char* value, size_t value_length);
+
+  virtual ~leveldb_mergeoperator_t() {
+    (*destructor_)(state_);
+  }
+
+  virtual const char* Name() const {
+    return (*name_)(state_);
+  }
+
+  virtual bool FullMergeV2(const MergeOperationInput& merge_in,
+                           MergeOperationOutput* merge_out) const {
+    size_t n = merge_in.operand_list.size();
+    std::vector<const char*> operand_pointers(n);
+    std::vector<size_t> operand_sizes(n);
+    for (size_t i = 0; i < n; i++) {
+      operand_pointers[i] = merge_in.operand_list[i].data();
+      operand_sizes[i] = merge_in.operand_list[i].size();
+    }
+
+    const char* existing_value_data = NULL;
+    size_t existing_value_len = 0;
+    if (merge_in.existing_value != NULL) {
+      existing_value_data = merge_in.existing_value->data();
+      existing_value_len = merge_in.existing_value->size();
+    }
+
+    unsigned char success;
+    size_t new_value_len;
+    char* tmp_new_value = (*full_merge_)(
+        state_, merge_in.key.data(), merge_in.key.size(),
+        existing_value_data, existing_value_len,
+        n > 0 ? &operand_pointers[0] : NULL,
+        n > 0 ? &operand_sizes[0] : NULL,
+        static_cast<int>(n), &success, &new_value_len);
+    merge_out->new_value.assign(tmp_new_value, new_value_len);
+    (*delete_value_)(state_, tmp_new_value, new_value_len);
+    return success;
+  }
+
+  virtual bool PartialMerge(const Slice& key,
+                            const Slice& left_operand,
+                            const Slice& right_operand,
+                            std::string* new_value,
+                            Logger* logger) const {
+    unsigned char success;
+    size_t new_value_len;
+    char* tmp_new_value = (*partial_merge_)(
+        state_, key.data(), key.size(),
+        left_operand.data(), left_operand.size(),
+        right_operand.data(), right_operand.size(),
+        &success, &new_value_len);
+    new_value->assign(tmp_new_value, new_value_len);
+    (*delete_value_)(state_, tmp_new_value, new_value_len);
+    return success;
+  }
+};
+
+struct leveldb_slicetransform_t : public SliceTransform {
+  void* state_;
+  void (*destructor_)(void*);
+  char* (*transform_)(
+      void*,
+      const char* key, size_t length,
+      size_t* dst_length);
+  unsigned char (*in_domain_)(
+      void*,
+      const char* key, size_t length);
+  unsigned char (*in_range_)(
+      void*,
+      const char* key, size_t length);
+  const char* (*name_)(void*);
+
+  virtual ~leveldb_slicetransform_t() {
+    (*destructor_)(state_);
+  }
+
+  virtual const char* Name() const {
+    return (*name_)(state_);
+  }
+
+  virtual Slice Transform(const Slice& src) const {
+    size_t len;
+    char* dst = (*transform_)(state_, src.data(), src.size(), &len);
+    return Slice(dst, len);
+  }
+
+  virtual bool InDomain(const Slice& src) const {
+    return (*in_domain_)(state_, src.data(), src.size());
+  }
+
+  virtual bool InRange(const Slice& src) const {
+    return (*in_range_)(state_, src.data(), src.size());
+  }
+};
+
+struct leveldb_compactionfilter_t : public CompactionFilter {
+  void* state_;
+  void (*destructor_)(void*);
+  unsigned char (*filter_)(
+      void*,
+      int level,
+      const char* key, size_t key_length,
+      const char* existing_value, size_t value_length,
+      char** new_value, size_t* new_value_length,
+      unsigned char* value_changed);
+  const char* (*name_)(void*);
+
+  virtual ~leveldb_compactionfilter_t() {
+    (*destructor_)(state_);
+  }
+
+  virtual bool Filter(int level,
+                      const Slice& key,
+                      const Slice& existing_value,
+                      std::string* new_value,
+                      bool* value_changed) const {
+    char* c_new_value = NULL;
+    size_t new_value_length = 0;
+    unsigned char c_value_changed = 0;
+    unsigned char result = (*filter_)(
+        state_,
+        level,
+        key.data(), key.size(),
+        existing_value.data(), existing_value.size(),
+        &c_new_value, &new_value_length, &c_value_changed);
+    if (c_value_changed) {
+      new_value->assign(c_new_value, new_value_length);
+      *value_changed = true;
+    }
+    free(c_new_value);
+    return result;
+  }
+
+  virtual const char* Name() const {
+    return (*name_)(state_);
+  }
+};
+
 struct leveldb_env_t {
   Env* rep;
   bool is_default;
 };
 
+// The error string starts with the code and the subcode of the status, each
+// plus one so that they aren't NUL, which ratgo turns into a typed error.
+static char* EncodeStatus(const Status& s) {
+  std::string str(2, '\0');
+  str[0] = static_cast<char>(s.code() + 1);
+  str[1] = static_cast<char>(s.subcode() + 1);
+  str += s.ToString();
+  return strdup(str.c_str());
+}
+
 static bool SaveError(char** errptr, const Status& s) {
   assert(errptr != NULL);
   if (s.ok()) {
     return false;
   } else if (*errptr == NULL) {
-    *errptr = strdup(s.ToString().c_str());
+    *errptr = EncodeStatus(s);
   } else {
     // TODO(sanjay): Merge with existing error?
     free(*errptr);
-    *errptr = strdup(s.ToString().c_str());
+    *errptr = EncodeStatus(s);
   }
   return true;
 }
@@ -175,111 +391,689 @@ leveldb_t* leveldb_open(
   return result;
 }
 
+leveldb_t* leveldb_open_for_read_only(
+    const leveldb_options_t* options,
+    const char* name,
+    unsigned char error_if_log_file_exist,
+    char** errptr) {
+  DB* db;
+  if (SaveError(errptr, DB::OpenForReadOnly(options->rep, std::string(name),
+                                            &db, error_if_log_file_exist))) {
+    return NULL;
+  }
+  leveldb_t* result = new leveldb_t;
+  result->rep = db;
+  return result;
+}
+
+leveldb_t* leveldb_open_as_secondary(
+    const leveldb_options_t* options,
+    const char* name,
+    const char* secondary_path,
+    char** errptr) {
+  DB* db;
+  if (SaveError(errptr, DB::OpenAsSecondary(options->rep, std::string(name),
+                                            std::string(secondary_path), &db))) {
+    return NULL;
+  }
+  leveldb_t* result = new leveldb_t;
+  result->rep = db;
+  return result;
+}
+
+void leveldb_try_catch_up_with_primary(leveldb_t* db, char** errptr) {
+  SaveError(errptr, db->rep->TryCatchUpWithPrimary());
+}
+
 void leveldb_close(leveldb_t* db) {
   delete db->rep;
   delete db;
 }
 
-void leveldb_put(
-    leveldb_t* db,
-    const leveldb_writeoptions_t* options,
+leveldb_t* leveldb_open_column_families(
+    const leveldb_options_t* db_options,
+    const char* name,
+    int num_column_families,
+    const char* const* column_family_names,
+    const leveldb_options_t* const* column_family_options,
+    leveldb_column_family_handle_t** column_family_handles,
+    char** errptr) {
+  std::vector<ColumnFamilyDescriptor> column_families;
+  for (int i = 0; i < num_column_families; i++) {
+    column_families.push_back(ColumnFamilyDescriptor(
+        std::string(column_family_names[i]),
+        ColumnFamilyOptions(column_family_options[i]->rep)));
+  }
+
+  DB* db;
+  std::vector<ColumnFamilyHandle*> handles;
+  if (SaveError(errptr, DB::Open(DBOptions(db_options->rep),
+          std::string(name), column_families, &handles, &db))) {
+    return NULL;
+  }
+
+  for (size_t i = 0; i < handles.size(); i++) {
+    leveldb_column_family_handle_t* c_handle =
+        new leveldb_column_family_handle_t;
+    c_handle->rep = handles[i];
+    column_family_handles[i] = c_handle;
+  }
+  leveldb_t* result = new leveldb_t;
+  result->rep = db;
+  return result;
+}
+
+char** leveldb_list_column_families(
+    const leveldb_options_t* options,
+    const char* name,
+    size_t* lencfs,
+    char** errptr) {
+  std::vector<std::string> fams;
+  SaveError(errptr,
+      DB::ListColumnFamilies(DBOptions(options->rep),
+        std::string(name), &fams));
+
+  *lencfs = fams.size();
+  char** column_families = static_cast<char**>(
+      malloc(sizeof(char*) * fams.size()));
+  for (size_t i = 0; i < fams.size(); i++) {
+    column_families[i] = strdup(fams[i].c_str());
+  }
+  return column_families;
+}
+
+void leveldb_list_column_families_destroy(char** list, size_t len) {
+  for (size_t i = 0; i < len; ++i) {
+    free(list[i]);
+  }
+  free(list);
+}
+
+leveldb_column_family_handle_t* leveldb_create_column_family(
+    leveldb_t* db,
+    const leveldb_options_t* column_family_options,
+    const char* column_family_name,
+    char** errptr) {
+  leveldb_column_family_handle_t* handle = new leveldb_column_family_handle_t;
+  if (SaveError(errptr,
+      db->rep->CreateColumnFamily(ColumnFamilyOptions(column_family_options->rep),
+        std::string(column_family_name), &(handle->rep)))) {
+    delete handle;
+    return NULL;
+  }
+  return handle;
+}
+
+void leveldb_drop_column_family(
+    leveldb_t* db,
+    leveldb_column_family_handle_t* handle,
+    char** errptr) {
+  SaveError(errptr, db->rep->DropColumnFamily(handle->rep));
+}
+
+void leveldb_column_family_handle_destroy(
+    leveldb_column_family_handle_t* handle) {
+  delete handle->rep;
+  delete handle;
+}
+
+void leveldb_put(
+    leveldb_t* db,
+    const leveldb_writeoptions_t* options,
+    const char* key, size_t keylen,
+    const char* val, size_t vallen,
+    char** errptr) {
+  SaveError(errptr,
+            db->rep->Put(options->rep, Slice(key, keylen), Slice(val, vallen)));
+}
+
+void leveldb_delete(
+    leveldb_t* db,
+    const leveldb_writeoptions_t* options,
+    const char* key, size_t keylen,
+    char** errptr) {
+  SaveError(errptr, db->rep->Delete(options->rep, Slice(key, keylen)));
+}
+
+void leveldb_merge(
+    leveldb_t* db,
+    const leveldb_writeoptions_t* options,
+    const char* key, size_t keylen,
+    const char* val, size_t vallen,
+    char** errptr) {
+  SaveError(errptr,
+            db->rep->Merge(options->rep, Slice(key, keylen), Slice(val, vallen)));
+}
+
+
+void leveldb_put_cf(
+    leveldb_t* db,
+    const leveldb_writeoptions_t* options,
+    leveldb_column_family_handle_t* column_family,
+    const char* key, size_t keylen,
+    const char* val, size_t vallen,
+    char** errptr) {
+  SaveError(errptr,
+            db->rep->Put(options->rep, column_family->rep,
+              Slice(key, keylen), Slice(val, vallen)));
+}
+
+void leveldb_delete_cf(
+    leveldb_t* db,
+    const leveldb_writeoptions_t* options,
+    leveldb_column_family_handle_t* column_family,
+    const char* key, size_t keylen,
+    char** errptr) {
+  SaveError(errptr, db->rep->Delete(options->rep, column_family->rep,
+        Slice(key, keylen)));
+}
+
+void leveldb_merge_cf(
+    leveldb_t* db,
+    const leveldb_writeoptions_t* options,
+    leveldb_column_family_handle_t* column_family,
+    const char* key, size_t keylen,
+    const char* val, size_t vallen,
+    char** errptr) {
+  SaveError(errptr,
+            db->rep->Merge(options->rep, column_family->rep,
+              Slice(key, keylen), Slice(val, vallen)));
+}
+
+void leveldb_write(
+    leveldb_t* db,
+    const leveldb_writeoptions_t* options,
+    leveldb_writebatch_t* batch,
+    char** errptr) {
+  SaveError(errptr, db->rep->Write(options->rep, &batch->rep));
+}
+
+char* leveldb_get(
+    leveldb_t* db,
+    const leveldb_readoptions_t* options,
+    const char* key, size_t keylen,
+    size_t* vallen,
+    char** errptr) {
+  char* result = NULL;
+  std::string tmp;
+  Status s = db->rep->Get(options->rep, Slice(key, keylen), &tmp);
+  if (s.ok()) {
+    *vallen = tmp.size();
+    result = CopyString(tmp);
+  } else {
+    *vallen = 0;
+    if (!s.IsNotFound()) {
+      SaveError(errptr, s);
+    }
+  }
+  return result;
+}
+
+leveldb_pinnableslice_t* leveldb_get_pinned(
+    leveldb_t* db,
+    const leveldb_readoptions_t* options,
+    const char* key, size_t keylen,
+    char** errptr) {
+  leveldb_pinnableslice_t* result = new leveldb_pinnableslice_t;
+  Status s = db->rep->Get(options->rep, db->rep->DefaultColumnFamily(),
+                          Slice(key, keylen), &result->rep);
+  if (!s.ok()) {
+    delete result;
+    if (!s.IsNotFound()) {
+      SaveError(errptr, s);
+    }
+    return NULL;
+  }
+  return result;
+}
+
+const char* leveldb_pinnableslice_value(
+    const leveldb_pinnableslice_t* slice,
+    size_t* vallen) {
+  *vallen = slice->rep.size();
+  return slice->rep.data();
+}
+
+void leveldb_pinnableslice_destroy(leveldb_pinnableslice_t* slice) {
+  delete slice;
+}
+
+char* leveldb_get_cf(
+    leveldb_t* db,
+    const leveldb_readoptions_t* options,
+    leveldb_column_family_handle_t* column_family,
+    const char* key, size_t keylen,
+    size_t* vallen,
+    char** errptr) {
+  char* result = NULL;
+  std::string tmp;
+  Status s = db->rep->Get(options->rep, column_family->rep,
+      Slice(key, keylen), &tmp);
+  if (s.ok()) {
+    *vallen = tmp.size();
+    result = CopyString(tmp);
+  } else {
+    *vallen = 0;
+    if (!s.IsNotFound()) {
+      SaveError(errptr, s);
+    }
+  }
+  return result;
+}
+
+void leveldb_multi_get(
+    leveldb_t* db,
+    const leveldb_readoptions_t* options,
+    int key_num,
+    const char* const* key_array,
+    const size_t* key_array_length,
+    char*** value_array,
+    size_t** value_array_length,
+    char*** errsptr) {
+  int i;
+  std::vector<Slice> key_vector;
+  std::vector<std::string> value_vector;
+  for (i = 0; i < key_num; i++) {
+    key_vector.push_back(Slice(key_array[i], key_array_length[i]));
+  }
+  std::vector<Status> status_vector = db->rep->MultiGet(options->rep, key_vector, &value_vector);
+  *value_array = static_cast<char**>(malloc(sizeof(char*) * key_num));
+  *value_array_length = static_cast<size_t*>(malloc(sizeof(size_t) * key_num));
+  *errsptr = static_cast<char**>(malloc(sizeof(char*) * key_num));
+  for (i = 0; i < key_num; i++) {
+    (*value_array)[i] = NULL;
+    (*value_array_length)[i] = 0;
+    (*errsptr)[i] = NULL;
+    if (status_vector[i].ok()) {
+      (*value_array_length)[i] = value_vector[i].size();
+      (*value_array)[i] = CopyString(value_vector[i]);
+    } else if (!status_vector[i].IsNotFound()) {
+      SaveError(&((*errsptr)[i]), status_vector[i]);
+    }
+  }
+}
+
+void leveldb_multi_get_cf(
+    leveldb_t* db,
+    const leveldb_readoptions_t* options,
+    leveldb_column_family_handle_t* const* column_families,
+    int key_num,
+    const char* const* key_array,
+    const size_t* key_array_length,
+    char*** value_array,
+    size_t** value_array_length,
+    char*** errsptr) {
+  std::vector<ColumnFamilyHandle*> cfs(key_num);
+  std::vector<Slice> key_vector(key_num);
+  for (int i = 0; i < key_num; i++) {
+    cfs[i] = column_families[i]->rep;
+    key_vector[i] = Slice(key_array[i], key_array_length[i]);
+  }
+  std::vector<std::string> value_vector;
+  std::vector<Status> status_vector =
+      db->rep->MultiGet(options->rep, cfs, key_vector, &value_vector);
+  *value_array = static_cast<char**>(malloc(sizeof(char*) * key_num));
+  *value_array_length = static_cast<size_t*>(malloc(sizeof(size_t) * key_num));
+  *errsptr = static_cast<char**>(malloc(sizeof(char*) * key_num));
+  for (int i = 0; i < key_num; i++) {
+    (*value_array)[i] = NULL;
+    (*value_array_length)[i] = 0;
+    (*errsptr)[i] = NULL;
+    if (status_vector[i].ok()) {
+      (*value_array_length)[i] = value_vector[i].size();
+      (*value_array)[i] = CopyString(value_vector[i]);
+    } else if (!status_vector[i].IsNotFound()) {
+      SaveError(&((*errsptr)[i]), status_vector[i]);
+    }
+  }
+}
+
+leveldb_iterator_t* leveldb_create_iterator(
+    leveldb_t* db,
+    const leveldb_readoptions_t* options) {
+  leveldb_iterator_t* result = new leveldb_iterator_t;
+  result->options = leveldb_readoptions_copy(options);
+  result->rep = db->rep->NewIterator(result->options->rep);
+  return result;
+}
+
+leveldb_iterator_t* leveldb_create_iterator_cf(
+    leveldb_t* db,
+    const leveldb_readoptions_t* options,
+    leveldb_column_family_handle_t* column_family) {
+  leveldb_iterator_t* result = new leveldb_iterator_t;
+  result->options = leveldb_readoptions_copy(options);
+  result->rep = db->rep->NewIterator(result->options->rep, column_family->rep);
+  return result;
+}
+
+leveldb_t* leveldb_open_with_ttl(
+    const leveldb_options_t* options,
+    const char* name,
+    int ttl,
+    char** errptr) {
+  DBWithTTL* db;
+  if (SaveError(errptr, DBWithTTL::Open(options->rep, std::string(name),
+                                        &db, ttl))) {
+    return NULL;
+  }
+  leveldb_t* result = new leveldb_t;
+  result->rep = db;
+  return result;
+}
+
+leveldb_t* leveldb_open_column_families_with_ttl(
+    const leveldb_options_t* db_options,
+    const char* name,
+    int num_column_families,
+    const char* const* column_family_names,
+    const leveldb_options_t* const* column_family_options,
+    const int* ttls,
+    leveldb_column_family_handle_t** column_family_handles,
+    char** errptr) {
+  std::vector<ColumnFamilyDescriptor> column_families;
+  std::vector<int32_t> column_family_ttls;
+  for (int i = 0; i < num_column_families; i++) {
+    column_families.push_back(ColumnFamilyDescriptor(
+        std::string(column_family_names[i]),
+        ColumnFamilyOptions(column_family_options[i]->rep)));
+    column_family_ttls.push_back(ttls[i]);
+  }
+
+  DBWithTTL* db;
+  std::vector<ColumnFamilyHandle*> handles;
+  if (SaveError(errptr, DBWithTTL::Open(DBOptions(db_options->rep),
+          std::string(name), column_families, &handles, &db,
+          column_family_ttls))) {
+    return NULL;
+  }
+
+  for (size_t i = 0; i < handles.size(); i++) {
+    leveldb_column_family_handle_t* c_handle =
+        new leveldb_column_family_handle_t;
+    c_handle->rep = handles[i];
+    column_family_handles[i] = c_handle;
+  }
+  leveldb_t* result = new leveldb_t;
+  result->rep = db;
+  return result;
+}
+
+void leveldb_set_ttl(
+    leveldb_t* db,
+    leveldb_column_family_handle_t* column_family,
+    int ttl) {
+  DBWithTTL* ttl_db = static_cast<DBWithTTL*>(db->rep);
+  ttl_db->SetTtl(column_family ? column_family->rep
+                               : ttl_db->DefaultColumnFamily(), ttl);
+}
+
+void leveldb_put_with_ttl(
+    leveldb_t* db,
+    const leveldb_writeoptions_t* options,
+    leveldb_column_family_handle_t* column_family,
+    const char* key, size_t keylen,
+    const char* val, size_t vallen,
+    int ttl,
+    int column_family_ttl,
+    char** errptr) {
+  DBWithTTL* ttl_db = static_cast<DBWithTTL*>(db->rep);
+  int64_t now;
+  if (SaveError(errptr, ttl_db->GetEnv()->GetCurrentTime(&now))) {
+    return;
+  }
+  // A value expires column_family_ttl seconds after the timestamp that is
+  // appended to it, so the timestamp is moved to make it expire after ttl.
+  uint32_t ts = static_cast<uint32_t>(now + ttl - column_family_ttl);
+  std::string value(val, vallen);
+  for (int i = 0; i < 4; i++) {
+    value.push_back(static_cast<char>((ts >> (8 * i)) & 0xff));
+  }
+  SaveError(errptr,
+            ttl_db->GetBaseDB()->Put(options->rep,
+              column_family ? column_family->rep
+                            : ttl_db->DefaultColumnFamily(),
+              Slice(key, keylen), value));
+}
+
+leveldb_t* leveldb_optimistictransactiondb_open(
+    const leveldb_options_t* options,
+    const char* name,
+    char** errptr) {
+  OptimisticTransactionDB* db;
+  if (SaveError(errptr, OptimisticTransactionDB::Open(
+          options->rep, std::string(name), &db))) {
+    return NULL;
+  }
+  leveldb_t* result = new leveldb_t;
+  result->rep = db;
+  return result;
+}
+
+leveldb_transaction_t* leveldb_optimistictransaction_begin(
+    leveldb_t* db,
+    const leveldb_writeoptions_t* write_options,
+    unsigned char set_snapshot) {
+  OptimisticTransactionOptions options;
+  options.set_snapshot = set_snapshot;
+  leveldb_transaction_t* result = new leveldb_transaction_t;
+  result->rep = static_cast<OptimisticTransactionDB*>(db->rep)
+                    ->BeginTransaction(write_options->rep, options);
+  return result;
+}
+
+void leveldb_transaction_destroy(leveldb_transaction_t* txn) {
+  delete txn->rep;
+  delete txn;
+}
+
+void leveldb_transaction_commit(
+    leveldb_transaction_t* txn,
+    char** errptr) {
+  SaveError(errptr, txn->rep->Commit());
+}
+
+void leveldb_transaction_rollback(
+    leveldb_transaction_t* txn,
+    char** errptr) {
+  SaveError(errptr, txn->rep->Rollback());
+}
+
+const leveldb_snapshot_t* leveldb_transaction_get_snapshot(
+    leveldb_transaction_t* txn) {
+  const Snapshot* snapshot = txn->rep->GetSnapshot();
+  if (snapshot == NULL) {
+    return NULL;
+  }
+  leveldb_snapshot_t* result = new leveldb_snapshot_t;
+  result->rep = snapshot;
+  return result;
+}
+
+void leveldb_transaction_snapshot_destroy(
+    const leveldb_snapshot_t* snapshot) {
+  delete snapshot;
+}
+
+char* leveldb_transaction_get(
+    leveldb_transaction_t* txn,
+    const leveldb_readoptions_t* options,
+    const char* key, size_t keylen,
+    size_t* vallen,
+    char** errptr) {
+  char* result = NULL;
+  std::string tmp;
+  Status s = txn->rep->Get(options->rep, Slice(key, keylen), &tmp);
+  if (s.ok()) {
+    *vallen = tmp.size();
+    result = CopyString(tmp);
+  } else {
+    *vallen = 0;
+    if (!s.IsNotFound()) {
+      SaveError(errptr, s);
+    }
+  }
+  return result;
+}
+
+char* leveldb_transaction_get_for_update(
+    leveldb_transaction_t* txn,
+    const leveldb_readoptions_t* options,
+    const char* key, size_t keylen,
+    size_t* vallen,
+    unsigned char exclusive,
+    char** errptr) {
+  char* result = NULL;
+  std::string tmp;
+  Status s = txn->rep->GetForUpdate(options->rep, Slice(key, keylen), &tmp,
+                                    exclusive);
+  if (s.ok()) {
+    *vallen = tmp.size();
+    result = CopyString(tmp);
+  } else {
+    *vallen = 0;
+    if (!s.IsNotFound()) {
+      SaveError(errptr, s);
+    }
+  }
+  return result;
+}
+
+void leveldb_transaction_put(
+    leveldb_transaction_t* txn,
     const char* key, size_t keylen,
     const char* val, size_t vallen,
     char** errptr) {
-  SaveError(errptr,
-            db->rep->Put(options->rep, Slice(key, keylen), Slice(val, vallen)));
+  SaveError(errptr, txn->rep->Put(Slice(key, keylen), Slice(val, vallen)));
 }
 
-void leveldb_delete(
-    leveldb_t* db,
-    const leveldb_writeoptions_t* options,
+void leveldb_transaction_delete(
+    leveldb_transaction_t* txn,
     const char* key, size_t keylen,
     char** errptr) {
-  SaveError(errptr, db->rep->Delete(options->rep, Slice(key, keylen)));
+  SaveError(errptr, txn->rep->Delete(Slice(key, keylen)));
 }
 
-void leveldb_merge(
-    leveldb_t* db,
-    const leveldb_writeoptions_t* options,
+void leveldb_transaction_merge(
+    leveldb_transaction_t* txn,
     const char* key, size_t keylen,
     const char* val, size_t vallen,
     char** errptr) {
-  SaveError(errptr,
-            db->rep->Merge(options->rep, Slice(key, keylen), Slice(val, vallen)));
+  SaveError(errptr, txn->rep->Merge(Slice(key, keylen), Slice(val, vallen)));
 }
 
+leveldb_iterator_t* leveldb_transaction_create_iterator(
+    leveldb_transaction_t* txn,
+    const leveldb_readoptions_t* options) {
+  leveldb_iterator_t* result = new leveldb_iterator_t;
+  result->options = leveldb_readoptions_copy(options);
+  result->rep = txn->rep->GetIterator(result->options->rep);
+  return result;
+}
 
-void leveldb_write(
-    leveldb_t* db,
-    const leveldb_writeoptions_t* options,
-    leveldb_writebatch_t* batch,
+leveldb_t* leveldb_transactiondb_open(
+    const leveldb_options_t* options,
+    const leveldb_transactiondb_options_t* txn_db_options,
+    const char* name,
     char** errptr) {
-  SaveError(errptr, db->rep->Write(options->rep, &batch->rep));
+  TransactionDB* db;
+  if (SaveError(errptr, TransactionDB::Open(options->rep, txn_db_options->rep,
+                                            std::string(name), &db))) {
+    return NULL;
+  }
+  leveldb_t* result = new leveldb_t;
+  result->rep = db;
+  return result;
 }
 
-char* leveldb_get(
+leveldb_transaction_t* leveldb_transaction_begin(
     leveldb_t* db,
-    const leveldb_readoptions_t* options,
-    const char* key, size_t keylen,
-    size_t* vallen,
-    char** errptr) {
-  char* result = NULL;
-  std::string tmp;
-  Status s = db->rep->Get(options->rep, Slice(key, keylen), &tmp);
-  if (s.ok()) {
-    *vallen = tmp.size();
-    result = CopyString(tmp);
-  } else {
-    *vallen = 0;
-    if (!s.IsNotFound()) {
-      SaveError(errptr, s);
-    }
+    const leveldb_writeoptions_t* write_options,
+    const leveldb_transaction_options_t* txn_options) {
+  leveldb_transaction_t* result = new leveldb_transaction_t;
+  result->rep = static_cast<TransactionDB*>(db->rep)
+                    ->BeginTransaction(write_options->rep, txn_options->rep);
+  return result;
+}
+
+leveldb_transaction_t** leveldb_transactiondb_get_prepared_transactions(
+    leveldb_t* db,
+    size_t* cnt) {
+  std::vector<Transaction*> txns;
+  static_cast<TransactionDB*>(db->rep)->GetAllPreparedTransactions(&txns);
+  *cnt = txns.size();
+  leveldb_transaction_t** result = static_cast<leveldb_transaction_t**>(
+      malloc(sizeof(leveldb_transaction_t*) * (txns.size() + 1)));
+  for (size_t i = 0; i < txns.size(); i++) {
+    result[i] = new leveldb_transaction_t;
+    result[i]->rep = txns[i];
   }
   return result;
 }
 
-void leveldb_multi_get(
+size_t leveldb_transactiondb_get_last_deadlock(
     leveldb_t* db,
-    const leveldb_readoptions_t* options,
-    int key_num,
-    const char* const* key_array,
-    const size_t* key_array_length,
-    char*** value_array,
-    size_t** value_array_length,
-    char*** errsptr) {
-  int i;
-  std::vector<Slice> key_vector;
-  std::vector<std::string> value_vector;
-  for (i = 0; i < key_num; i++) {
-    key_vector.push_back(Slice(key_array[i], key_array_length[i]));
+    uint64_t** txn_ids,
+    uint32_t** column_family_ids,
+    char*** keys,
+    size_t** key_lens,
+    unsigned char** exclusive) {
+  // The buffer holds the most recent deadlock first.
+  std::vector<rocksdb::DeadlockPath> paths =
+      static_cast<TransactionDB*>(db->rep)->GetDeadlockInfoBuffer();
+  if (paths.empty() || paths[0].path.empty()) {
+    return 0;
   }
-  std::vector<Status> status_vector = db->rep->MultiGet(options->rep, key_vector, &value_vector);
-  *value_array = new char*[key_num];
-  *value_array_length = new size_t[key_num];
-  *errsptr = new char*[key_num];
-  for (i = 0; i < key_num; i++) {
-    if (status_vector[i].ok()) {
-      (*value_array_length)[i] = value_vector[i].size();
-      (*value_array)[i] = CopyString(value_vector[i]);
-      (*errsptr)[i] = NULL;
-    } else {
-      if (status_vector[i].IsNotFound()) {
-        (*value_array_length)[i] = 0;
-        (*value_array)[i] = NULL;
-        (*errsptr)[i] = NULL;
-      } else {
-        SaveError(&((*errsptr)[i]), status_vector[i]);
-      }
-    }
+  const std::vector<rocksdb::DeadlockInfo>& path = paths[0].path;
+  size_t n = path.size();
+  *txn_ids = static_cast<uint64_t*>(malloc(sizeof(uint64_t) * n));
+  *column_family_ids = static_cast<uint32_t*>(malloc(sizeof(uint32_t) * n));
+  *keys = static_cast<char**>(malloc(sizeof(char*) * n));
+  *key_lens = static_cast<size_t*>(malloc(sizeof(size_t) * n));
+  *exclusive = static_cast<unsigned char*>(malloc(n));
+  for (size_t i = 0; i < n; i++) {
+    (*txn_ids)[i] = path[i].m_txn_id;
+    (*column_family_ids)[i] = path[i].m_cf_id;
+    (*keys)[i] = CopyString(path[i].m_waiting_key);
+    (*key_lens)[i] = path[i].m_waiting_key.size();
+    (*exclusive)[i] = path[i].m_exclusive;
   }
+  return n;
 }
 
-leveldb_iterator_t* leveldb_create_iterator(
-    leveldb_t* db,
-    const leveldb_readoptions_t* options) {
-  leveldb_iterator_t* result = new leveldb_iterator_t;
-  result->rep = db->rep->NewIterator(options->rep);
-  return result;
+uint64_t leveldb_transaction_get_id(leveldb_transaction_t* txn) {
+  return txn->rep->GetID();
+}
+
+void leveldb_transaction_set_name(
+    leveldb_transaction_t* txn,
+    const char* name, size_t namelen,
+    char** errptr) {
+  SaveError(errptr, txn->rep->SetName(std::string(name, namelen)));
+}
+
+char* leveldb_transaction_get_name(
+    leveldb_transaction_t* txn,
+    size_t* namelen) {
+  std::string name = txn->rep->GetName();
+  *namelen = name.size();
+  return CopyString(name);
+}
+
+void leveldb_transaction_prepare(
+    leveldb_transaction_t* txn,
+    char** errptr) {
+  SaveError(errptr, txn->rep->Prepare());
+}
+
+void leveldb_transaction_set_savepoint(leveldb_transaction_t* txn) {
+  txn->rep->SetSavePoint();
+}
+
+void leveldb_transaction_rollback_to_savepoint(
+    leveldb_transaction_t* txn,
+    char** errptr) {
+  SaveError(errptr, txn->rep->RollbackToSavePoint());
 }
 
 const leveldb_snapshot_t* leveldb_create_snapshot(
@@ -329,6 +1123,7 @@ void leveldb_compact_range(
     const char* limit_key, size_t limit_key_len) {
   Slice a, b;
   db->rep->CompactRange(
+      CompactRangeOptions(),
       // Pass NULL Slice if corresponding "const char*" is NULL
       (start_key ? (a = Slice(start_key, start_key_len), &a) : NULL),
       (limit_key ? (b = Slice(limit_key, limit_key_len), &b) : NULL));
@@ -349,12 +1144,15 @@ void leveldb_repair_db(
 }
 
 void leveldb_disable_file_deletions(
-  leveldb_t *db) {
-  db->rep->DisableFileDeletions();
+  leveldb_t *db,
+  char** errptr) {
+  SaveError(errptr, db->rep->DisableFileDeletions());
 }
 
 void leveldb_enable_file_deletions(leveldb_t *db) {
-  db->rep->EnableFileDeletions();
+  // Not forced, so that every DisableFileDeletions has to be undone before
+  // files are deleted again.
+  db->rep->EnableFileDeletions(false);
 }
 
 void leveldb_get_live_files(
@@ -394,6 +1192,7 @@ void leveldb_flush(leveldb_t *db, leveldb_flushoptions_t* options, char** errptr
 
 void leveldb_iter_destroy(leveldb_iterator_t* iter) {
   delete iter->rep;
+  leveldb_readoptions_destroy(iter->options);
   delete iter;
 }
 
@@ -413,6 +1212,10 @@ void leveldb_iter_seek(leveldb_iterator_t* iter, const char* k, size_t klen) {
   iter->rep->Seek(Slice(k, klen));
 }
 
+void leveldb_iter_seek_for_prev(leveldb_iterator_t* iter, const char* k, size_t klen) {
+  iter->rep->SeekForPrev(Slice(k, klen));
+}
+
 void leveldb_iter_next(leveldb_iterator_t* iter) {
   iter->rep->Next();
 }
@@ -437,6 +1240,35 @@ void leveldb_iter_get_error(const leveldb_iterator_t* iter, char** errptr) {
   SaveError(errptr, iter->rep->status());
 }
 
+size_t leveldb_iter_next_batch(
+    leveldb_iterator_t* iter,
+    size_t max_entries,
+    char* buf, size_t buflen,
+    size_t* lens,
+    size_t* needed) {
+  size_t n = 0, used = 0;
+  *needed = 0;
+  while (n < max_entries && iter->rep->Valid()) {
+    Slice k = iter->rep->key();
+    Slice v = iter->rep->value();
+    size_t size = k.size() + v.size();
+    if (size > buflen - used) {
+      if (n == 0) {
+        *needed = size;
+      }
+      break;
+    }
+    memcpy(buf + used, k.data(), k.size());
+    memcpy(buf + used + k.size(), v.data(), v.size());
+    used += size;
+    lens[2*n] = k.size();
+    lens[2*n+1] = v.size();
+    n++;
+    iter->rep->Next();
+  }
+  return n;
+}
+
 //
 // Write Batch
 //
@@ -460,12 +1292,42 @@ void leveldb_writebatch_put(
   b->rep.Put(Slice(key, klen), Slice(val, vlen));
 }
 
+void leveldb_writebatch_merge(
+    leveldb_writebatch_t* b,
+    const char* key, size_t klen,
+    const char* val, size_t vlen) {
+  b->rep.Merge(Slice(key, klen), Slice(val, vlen));
+}
+
 void leveldb_writebatch_delete(
     leveldb_writebatch_t* b,
     const char* key, size_t klen) {
   b->rep.Delete(Slice(key, klen));
 }
 
+void leveldb_writebatch_put_cf(
+    leveldb_writebatch_t* b,
+    leveldb_column_family_handle_t* column_family,
+    const char* key, size_t klen,
+    const char* val, size_t vlen) {
+  b->rep.Put(column_family->rep, Slice(key, klen), Slice(val, vlen));
+}
+
+void leveldb_writebatch_merge_cf(
+    leveldb_writebatch_t* b,
+    leveldb_column_family_handle_t* column_family,
+    const char* key, size_t klen,
+    const char* val, size_t vlen) {
+  b->rep.Merge(column_family->rep, Slice(key, klen), Slice(val, vlen));
+}
+
+void leveldb_writebatch_delete_cf(
+    leveldb_writebatch_t* b,
+    leveldb_column_family_handle_t* column_family,
+    const char* key, size_t klen) {
+  b->rep.Delete(column_family->rep, Slice(key, klen));
+}
+
 void leveldb_writebatch_iterate(
     leveldb_writebatch_t* b,
     void* state,
@@ -505,13 +1367,55 @@ void leveldb_options_destroy(leveldb_options_t* options) {
 void leveldb_options_set_comparator(
     leveldb_options_t* opt,
     leveldb_comparator_t* cmp) {
-  opt->rep.comparator = cmp;
+  if (cmp == NULL) {
+    opt->rep.comparator = rocksdb::BytewiseComparator();
+  } else {
+    opt->rep.comparator = cmp;
+  }
+}
+
+// Installs a table factory with the table options, after a setter changed
+// them.
+static void UpdateTableFactory(leveldb_options_t* opt) {
+  opt->rep.table_factory.reset(NewBlockBasedTableFactory(opt->table_options));
 }
 
+// The caller keeps owning the policy, which it destroys with
+// leveldb_filterpolicy_destroy once the databases using it are closed.
+static void DoNotDeleteFilterPolicy(const FilterPolicy*) { }
+
 void leveldb_options_set_filter_policy(
     leveldb_options_t* opt,
     leveldb_filterpolicy_t* policy) {
-  opt->rep.filter_policy = policy;
+  if (policy == NULL) {
+    opt->table_options.filter_policy.reset();
+  } else {
+    opt->table_options.filter_policy.reset(policy, &DoNotDeleteFilterPolicy);
+  }
+  UpdateTableFactory(opt);
+}
+
+void leveldb_options_set_merge_operator(
+    leveldb_options_t* opt,
+    leveldb_mergeoperator_t* merge_operator) {
+  opt->rep.merge_operator = std::shared_ptr<MergeOperator>(merge_operator);
+}
+
+void leveldb_options_set_compaction_filter(
+    leveldb_options_t* opt,
+    leveldb_compactionfilter_t* filter) {
+  opt->rep.compaction_filter = filter;
+}
+
+void leveldb_options_set_prefix_extractor(
+    leveldb_options_t* opt,
+    leveldb_slicetransform_t* prefix_extractor) {
+  opt->rep.prefix_extractor.reset(prefix_extractor);
+}
+
+void leveldb_options_set_memtable_prefix_bloom_size_ratio(
+    leveldb_options_t* opt, double v) {
+  opt->rep.memtable_prefix_bloom_size_ratio = v;
 }
 
 void leveldb_options_set_create_if_missing(
@@ -529,6 +1433,11 @@ void leveldb_options_set_paranoid_checks(
   opt->rep.paranoid_checks = v;
 }
 
+void leveldb_options_set_allow_2pc(
+    leveldb_options_t* opt, unsigned char v) {
+  opt->rep.allow_2pc = v;
+}
+
 void leveldb_options_set_env(leveldb_options_t* opt, leveldb_env_t* env) {
   opt->rep.env = (env ? env->rep : NULL);
 }
@@ -564,13 +1473,15 @@ void leveldb_options_set_max_open_files(leveldb_options_t* opt, int n) {
 
 void leveldb_options_set_cache(leveldb_options_t* opt, leveldb_cache_t* c) {
   if (c) {
-    opt->rep.block_cache = c->rep;
+    opt->table_options.block_cache = c->rep;
+    UpdateTableFactory(opt);
   }
 }
 
 void leveldb_options_set_compressed_cache(leveldb_options_t* opt, leveldb_cache_t* c) {
   if (c) {
-    opt->rep.block_cache_compressed = c->rep;
+    opt->table_options.block_cache_compressed = c->rep;
+    UpdateTableFactory(opt);
   }
 }
 
@@ -579,11 +1490,13 @@ void leveldb_options_set_compressed_cache(leveldb_options_t* opt, leveldb_cache_
 //
 
 void leveldb_options_set_block_size(leveldb_options_t* opt, size_t s) {
-  opt->rep.block_size = s;
+  opt->table_options.block_size = s;
+  UpdateTableFactory(opt);
 }
 
 void leveldb_options_set_block_restart_interval(leveldb_options_t* opt, int n) {
-  opt->rep.block_restart_interval = n;
+  opt->table_options.block_restart_interval = n;
+  UpdateTableFactory(opt);
 }
 
 //
@@ -610,16 +1523,6 @@ void leveldb_options_set_max_bytes_for_level_multiplier(
   opt->rep.max_bytes_for_level_multiplier = n;
 }
 
-void leveldb_options_set_expanded_compaction_factor(
-    leveldb_options_t* opt, int n) {
-  opt->rep.expanded_compaction_factor = n;
-}
-
-void leveldb_options_set_max_grandparent_overlap_factor(
-    leveldb_options_t* opt, int n) {
-  opt->rep.max_grandparent_overlap_factor = n;
-}
-
 void leveldb_options_set_num_levels(leveldb_options_t* opt, int n) {
   opt->rep.num_levels = n;
 }
@@ -639,11 +1542,6 @@ void leveldb_options_set_level0_stop_writes_trigger(
   opt->rep.level0_stop_writes_trigger = n;
 }
 
-void leveldb_options_set_max_mem_compaction_level(
-    leveldb_options_t* opt, int n) {
-  opt->rep.max_mem_compaction_level = n;
-}
-
 void leveldb_options_disable_auto_compaction(
     leveldb_options_t* opt, unsigned char v) {
   opt->rep.disable_auto_compactions = v;
@@ -670,11 +1568,6 @@ void leveldb_options_set_compression_options(
   opt->rep.compression_opts.strategy = strategy;
 }
 
-void leveldb_options_set_disable_data_sync(
-    leveldb_options_t* opt, unsigned char disable_data_sync) {
-  opt->rep.disableDataSync = disable_data_sync;
-}
-
 void leveldb_options_set_use_fsync(
     leveldb_options_t* opt, unsigned char use_fsync) {
   opt->rep.use_fsync = use_fsync;
@@ -684,11 +1577,6 @@ void leveldb_options_set_use_fsync(
 // Log options
 //
 
-void leveldb_options_set_db_stats_log_interval(
-    leveldb_options_t* opt, int db_stats_log_interval) {
-  opt->rep.db_stats_log_interval = db_stats_log_interval;
-}
-
 void leveldb_options_set_db_log_dir(
     leveldb_options_t* opt, const char* db_log_dir) {
   opt->rep.db_log_dir = db_log_dir;
@@ -766,16 +1654,173 @@ leveldb_filterpolicy_t* leveldb_filterpolicy_create_bloom(int bits_per_key) {
     static void DoNothing(void*) { }
   };
   Wrapper* wrapper = new Wrapper;
-  wrapper->rep_ = NewBloomFilterPolicy(bits_per_key);
+  // The wrapper only implements the block-based filter methods, which the
+  // policy must be built for.
+  wrapper->rep_ = NewBloomFilterPolicy(bits_per_key, true);
   wrapper->state_ = NULL;
   wrapper->destructor_ = &Wrapper::DoNothing;
   return wrapper;
 }
 
+char* leveldb_filterpolicy_create_filter(
+    leveldb_filterpolicy_t* policy,
+    const char* const* key_array, const size_t* key_length_array,
+    int num_keys,
+    size_t* filter_length) {
+  std::vector<Slice> keys(num_keys);
+  for (int i = 0; i < num_keys; i++) {
+    keys[i] = Slice(key_array[i], key_length_array[i]);
+  }
+  std::string dst;
+  policy->CreateFilter(num_keys > 0 ? &keys[0] : NULL, num_keys, &dst);
+  *filter_length = dst.size();
+  return CopyString(dst);
+}
+
+unsigned char leveldb_filterpolicy_key_may_match(
+    leveldb_filterpolicy_t* policy,
+    const char* key, size_t length,
+    const char* filter, size_t filter_length) {
+  return policy->KeyMayMatch(Slice(key, length), Slice(filter, filter_length));
+}
+
+leveldb_mergeoperator_t* leveldb_mergeoperator_create(
+    void* state,
+    void (*destructor)(void*),
+    char* (*full_merge)(
+        void*,
+        const char* key, size_t key_length,
+        const char* existing_value, size_t existing_value_length,
+        const char* const* operands_list, const size_t* operands_list_length,
+        int num_operands,
+        unsigned char* success, size_t* new_value_length),
+    char* (*partial_merge)(
+        void*,
+        const char* key, size_t key_length,
+        const char* left_operand, size_t left_operand_length,
+        const char* right_operand, size_t right_operand_length,
+        unsigned char* success, size_t* new_value_length),
+    void (*delete_value)(
+        void*,
+        const char* value, size_t value_length),
+    const char* (*name)(void*)) {
+  leveldb_mergeoperator_t* result = new leveldb_mergeoperator_t;
+  result->state_ = state;
+  result->destructor_ = destructor;
+  result->full_merge_ = full_merge;
+  result->partial_merge_ = partial_merge;
+  result->delete_value_ = delete_value;
+  result->name_ = name;
+  return result;
+}
+
+void leveldb_mergeoperator_destroy(leveldb_mergeoperator_t* merge_operator) {
+  delete merge_operator;
+}
+
+leveldb_compactionfilter_t* leveldb_compactionfilter_create(
+    void* state,
+    void (*destructor)(void*),
+    unsigned char (*filter)(
+        void*,
+        int level,
+        const char* key, size_t key_length,
+        const char* existing_value, size_t value_length,
+        char** new_value, size_t* new_value_length,
+        unsigned char* value_changed),
+    const char* (*name)(void*)) {
+  leveldb_compactionfilter_t* result = new leveldb_compactionfilter_t;
+  result->state_ = state;
+  result->destructor_ = destructor;
+  result->filter_ = filter;
+  result->name_ = name;
+  return result;
+}
+
+void leveldb_compactionfilter_destroy(leveldb_compactionfilter_t* filter) {
+  delete filter;
+}
+
+leveldb_slicetransform_t* leveldb_slicetransform_create(
+    void* state,
+    void (*destructor)(void*),
+    char* (*transform)(
+        void*,
+        const char* key, size_t length,
+        size_t* dst_length),
+    unsigned char (*in_domain)(
+        void*,
+        const char* key, size_t length),
+    unsigned char (*in_range)(
+        void*,
+        const char* key, size_t length),
+    const char* (*name)(void*)) {
+  leveldb_slicetransform_t* result = new leveldb_slicetransform_t;
+  result->state_ = state;
+  result->destructor_ = destructor;
+  result->transform_ = transform;
+  result->in_domain_ = in_domain;
+  result->in_range_ = in_range;
+  result->name_ = name;
+  return result;
+}
+
+namespace {
+
+// Wraps one of the slice transforms built into RocksDB.
+struct SliceTransformWrapper : public leveldb_slicetransform_t {
+  const SliceTransform* rep_;
+  ~SliceTransformWrapper() { delete rep_; }
+  const char* Name() const { return rep_->Name(); }
+  Slice Transform(const Slice& src) const { return rep_->Transform(src); }
+  bool InDomain(const Slice& src) const { return rep_->InDomain(src); }
+  bool InRange(const Slice& src) const { return rep_->InRange(src); }
+  static void DoNothing(void*) { }
+};
+
+}  // namespace
+
+leveldb_slicetransform_t* leveldb_slicetransform_create_fixed_prefix(
+    size_t prefix_len) {
+  SliceTransformWrapper* wrapper = new SliceTransformWrapper;
+  wrapper->rep_ = rocksdb::NewFixedPrefixTransform(prefix_len);
+  wrapper->state_ = NULL;
+  wrapper->destructor_ = &SliceTransformWrapper::DoNothing;
+  return wrapper;
+}
+
+leveldb_slicetransform_t* leveldb_slicetransform_create_capped_prefix(
+    size_t cap_len) {
+  SliceTransformWrapper* wrapper = new SliceTransformWrapper;
+  wrapper->rep_ = rocksdb::NewCappedPrefixTransform(cap_len);
+  wrapper->state_ = NULL;
+  wrapper->destructor_ = &SliceTransformWrapper::DoNothing;
+  return wrapper;
+}
+
+void leveldb_slicetransform_destroy(leveldb_slicetransform_t* st) {
+  delete st;
+}
+
 leveldb_readoptions_t* leveldb_readoptions_create() {
   return new leveldb_readoptions_t;
 }
 
+leveldb_readoptions_t* leveldb_readoptions_copy(
+    const leveldb_readoptions_t* opt) {
+  leveldb_readoptions_t* copy = new leveldb_readoptions_t(*opt);
+  // The bounds have to point into the copy.
+  if (copy->rep.iterate_lower_bound != NULL) {
+    copy->lower_bound_slice = Slice(copy->lower_bound);
+    copy->rep.iterate_lower_bound = &copy->lower_bound_slice;
+  }
+  if (copy->rep.iterate_upper_bound != NULL) {
+    copy->upper_bound_slice = Slice(copy->upper_bound);
+    copy->rep.iterate_upper_bound = &copy->upper_bound_slice;
+  }
+  return copy;
+}
+
 void leveldb_readoptions_destroy(leveldb_readoptions_t* opt) {
   delete opt;
 }
@@ -797,12 +1842,77 @@ void leveldb_readoptions_set_snapshot(
   opt->rep.snapshot = (snap ? snap->rep : NULL);
 }
 
-/*void leveldb_readoptions_set_read_prefix(
+void leveldb_readoptions_set_read_prefix(
     leveldb_readoptions_t* opt,
     const char* prefix,
     size_t prefix_len) {
-  opt->rep.prefix = &Slice(prefix, prefix_len);
-}*/
+  // The keys with the prefix are those from the prefix itself up to, but
+  // not including, the prefix with its last byte that isn't 0xff
+  // incremented and the bytes after it dropped.
+  opt->lower_bound.assign(prefix, prefix_len);
+  opt->lower_bound_slice = Slice(opt->lower_bound);
+  opt->rep.iterate_lower_bound = &opt->lower_bound_slice;
+
+  opt->upper_bound.assign(prefix, prefix_len);
+  while (!opt->upper_bound.empty() &&
+         static_cast<unsigned char>(opt->upper_bound.back()) == 0xff) {
+    opt->upper_bound.pop_back();
+  }
+  if (opt->upper_bound.empty()) {
+    // Every key from the prefix on has it.
+    opt->rep.iterate_upper_bound = NULL;
+    return;
+  }
+  opt->upper_bound.back()++;
+  opt->upper_bound_slice = Slice(opt->upper_bound);
+  opt->rep.iterate_upper_bound = &opt->upper_bound_slice;
+}
+
+void leveldb_readoptions_set_iterate_upper_bound(
+    leveldb_readoptions_t* opt,
+    const char* key, size_t keylen) {
+  if (key == NULL) {
+    opt->upper_bound.clear();
+    opt->rep.iterate_upper_bound = NULL;
+    return;
+  }
+  opt->upper_bound.assign(key, keylen);
+  opt->upper_bound_slice = Slice(opt->upper_bound);
+  opt->rep.iterate_upper_bound = &opt->upper_bound_slice;
+}
+
+void leveldb_readoptions_set_iterate_lower_bound(
+    leveldb_readoptions_t* opt,
+    const char* key, size_t keylen) {
+  if (key == NULL) {
+    opt->lower_bound.clear();
+    opt->rep.iterate_lower_bound = NULL;
+    return;
+  }
+  opt->lower_bound.assign(key, keylen);
+  opt->lower_bound_slice = Slice(opt->lower_bound);
+  opt->rep.iterate_lower_bound = &opt->lower_bound_slice;
+}
+
+void leveldb_readoptions_set_prefix_same_as_start(
+    leveldb_readoptions_t* opt, unsigned char v) {
+  opt->rep.prefix_same_as_start = v;
+}
+
+void leveldb_readoptions_set_total_order_seek(
+    leveldb_readoptions_t* opt, unsigned char v) {
+  opt->rep.total_order_seek = v;
+}
+
+void leveldb_readoptions_set_deadline(
+    leveldb_readoptions_t* opt, uint64_t deadline_us) {
+  opt->rep.deadline = std::chrono::microseconds(deadline_us);
+}
+
+void leveldb_readoptions_set_io_timeout(
+    leveldb_readoptions_t* opt, uint64_t timeout_us) {
+  opt->rep.io_timeout = std::chrono::microseconds(timeout_us);
+}
 
 leveldb_writeoptions_t* leveldb_writeoptions_create() {
   return new leveldb_writeoptions_t;
@@ -844,6 +1954,54 @@ void leveldb_env_destroy(leveldb_env_t* env) {
   delete env;
 }
 
+leveldb_transactiondb_options_t* leveldb_transactiondb_options_create() {
+  return new leveldb_transactiondb_options_t;
+}
+
+void leveldb_transactiondb_options_destroy(
+    leveldb_transactiondb_options_t* opt) {
+  delete opt;
+}
+
+void leveldb_transactiondb_options_set_transaction_lock_timeout(
+    leveldb_transactiondb_options_t* opt, int64_t v) {
+  opt->rep.transaction_lock_timeout = v;
+}
+
+void leveldb_transactiondb_options_set_default_lock_timeout(
+    leveldb_transactiondb_options_t* opt, int64_t v) {
+  opt->rep.default_lock_timeout = v;
+}
+
+void leveldb_transactiondb_options_set_max_num_deadlocks(
+    leveldb_transactiondb_options_t* opt, uint32_t v) {
+  opt->rep.max_num_deadlocks = v;
+}
+
+leveldb_transaction_options_t* leveldb_transaction_options_create() {
+  return new leveldb_transaction_options_t;
+}
+
+void leveldb_transaction_options_destroy(
+    leveldb_transaction_options_t* opt) {
+  delete opt;
+}
+
+void leveldb_transaction_options_set_set_snapshot(
+    leveldb_transaction_options_t* opt, unsigned char v) {
+  opt->rep.set_snapshot = v;
+}
+
+void leveldb_transaction_options_set_deadlock_detect(
+    leveldb_transaction_options_t* opt, unsigned char v) {
+  opt->rep.deadlock_detect = v;
+}
+
+void leveldb_transaction_options_set_lock_timeout(
+    leveldb_transaction_options_t* opt, int64_t v) {
+  opt->rep.lock_timeout = v;
+}
+
 leveldb_flushoptions_t* leveldb_flushoptions_create() {
   return new leveldb_flushoptions_t;
 }
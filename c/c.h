@@ -70,6 +70,14 @@ typedef struct leveldb_writablefile_t  leveldb_writablefile_t;
 typedef struct leveldb_writebatch_t    leveldb_writebatch_t;
 typedef struct leveldb_writeoptions_t  leveldb_writeoptions_t;
 typedef struct leveldb_flushoptions_t  leveldb_flushoptions_t;
+typedef struct leveldb_mergeoperator_t leveldb_mergeoperator_t;
+typedef struct leveldb_compactionfilter_t leveldb_compactionfilter_t;
+typedef struct leveldb_slicetransform_t leveldb_slicetransform_t;
+typedef struct leveldb_pinnableslice_t leveldb_pinnableslice_t;
+typedef struct leveldb_column_family_handle_t leveldb_column_family_handle_t;
+typedef struct leveldb_transaction_t   leveldb_transaction_t;
+typedef struct leveldb_transactiondb_options_t leveldb_transactiondb_options_t;
+typedef struct leveldb_transaction_options_t leveldb_transaction_options_t;
 
 
 /* DB operations */
@@ -79,8 +87,318 @@ extern leveldb_t* leveldb_open(
     const char* name,
     char** errptr);
 
+/* Opens a database that can only be read.  If error_if_log_file_exist is
+   non-zero, opening fails if the database has a non-empty write-ahead log,
+   that is, writes that were never flushed. */
+extern leveldb_t* leveldb_open_for_read_only(
+    const leveldb_options_t* options,
+    const char* name,
+    unsigned char error_if_log_file_exist,
+    char** errptr);
+
+/* Opens a secondary instance of the database at name, which keeps its own
+   information log and other files in secondary_path.  A secondary instance
+   can only be read, and follows the primary instance of the database with
+   leveldb_try_catch_up_with_primary. */
+extern leveldb_t* leveldb_open_as_secondary(
+    const leveldb_options_t* options,
+    const char* name,
+    const char* secondary_path,
+    char** errptr);
+
+extern void leveldb_try_catch_up_with_primary(leveldb_t* db, char** errptr);
+
 extern void leveldb_close(leveldb_t* db);
 
+/* Column families */
+
+/* Opens a database with the column families given, which must include
+   "default" and every column family of the database.  On success, the
+   handles of the column families are stored in column_family_handles in
+   the same order as their names. */
+extern leveldb_t* leveldb_open_column_families(
+    const leveldb_options_t* options,
+    const char* name,
+    int num_column_families,
+    const char* const* column_family_names,
+    const leveldb_options_t* const* column_family_options,
+    leveldb_column_family_handle_t** column_family_handles,
+    char** errptr);
+
+/* Returns a malloc()ed array of malloc()ed names, which is released with
+   leveldb_list_column_families_destroy. */
+extern char** leveldb_list_column_families(
+    const leveldb_options_t* options,
+    const char* name,
+    size_t* lencf,
+    char** errptr);
+
+extern void leveldb_list_column_families_destroy(char** list, size_t len);
+
+extern leveldb_column_family_handle_t* leveldb_create_column_family(
+    leveldb_t* db,
+    const leveldb_options_t* column_family_options,
+    const char* column_family_name,
+    char** errptr);
+
+extern void leveldb_drop_column_family(
+    leveldb_t* db,
+    leveldb_column_family_handle_t* handle,
+    char** errptr);
+
+/* Must be called for every handle before the database is closed. */
+extern void leveldb_column_family_handle_destroy(
+    leveldb_column_family_handle_t*);
+
+extern void leveldb_put_cf(
+    leveldb_t* db,
+    const leveldb_writeoptions_t* options,
+    leveldb_column_family_handle_t* column_family,
+    const char* key, size_t keylen,
+    const char* val, size_t vallen,
+    char** errptr);
+
+extern void leveldb_delete_cf(
+    leveldb_t* db,
+    const leveldb_writeoptions_t* options,
+    leveldb_column_family_handle_t* column_family,
+    const char* key, size_t keylen,
+    char** errptr);
+
+extern void leveldb_merge_cf(
+    leveldb_t* db,
+    const leveldb_writeoptions_t* options,
+    leveldb_column_family_handle_t* column_family,
+    const char* key, size_t keylen,
+    const char* val, size_t vallen,
+    char** errptr);
+
+extern char* leveldb_get_cf(
+    leveldb_t* db,
+    const leveldb_readoptions_t* options,
+    leveldb_column_family_handle_t* column_family,
+    const char* key, size_t keylen,
+    size_t* vallen,
+    char** errptr);
+
+/* Looks up key_array[i] in column_families[i].  The value, length and
+   error arrays are malloc()ed, and so are their non-NULL elements. */
+extern void leveldb_multi_get_cf(
+    leveldb_t* db,
+    const leveldb_readoptions_t* options,
+    leveldb_column_family_handle_t* const* column_families,
+    int key_num,
+    const char* const* key_array,
+    const size_t* key_array_length,
+    char*** value_array,
+    size_t** value_array_length,
+    char*** errsptr);
+
+extern leveldb_iterator_t* leveldb_create_iterator_cf(
+    leveldb_t* db,
+    const leveldb_readoptions_t* options,
+    leveldb_column_family_handle_t* column_family);
+
+/* TTL */
+
+/* Opens a database whose values expire ttl seconds after they were written;
+   expired values are dropped during compaction.  ttl <= 0 means that values
+   never expire. */
+extern leveldb_t* leveldb_open_with_ttl(
+    const leveldb_options_t* options,
+    const char* name,
+    int ttl,
+    char** errptr);
+
+/* Like leveldb_open_column_families, with the ttl of column_family_names[i]
+   in ttls[i]. */
+extern leveldb_t* leveldb_open_column_families_with_ttl(
+    const leveldb_options_t* options,
+    const char* name,
+    int num_column_families,
+    const char* const* column_family_names,
+    const leveldb_options_t* const* column_family_options,
+    const int* ttls,
+    leveldb_column_family_handle_t** column_family_handles,
+    char** errptr);
+
+/* Changes the ttl of a column family of a database opened with ttl.  A NULL
+   column_family is the default column family. */
+extern void leveldb_set_ttl(
+    leveldb_t* db,
+    leveldb_column_family_handle_t* column_family,
+    int ttl);
+
+/* Writes a value that expires ttl seconds from now, instead of after the ttl
+   of the column family, which is column_family_ttl and must be positive.  A
+   NULL column_family is the default column family. */
+extern void leveldb_put_with_ttl(
+    leveldb_t* db,
+    const leveldb_writeoptions_t* options,
+    leveldb_column_family_handle_t* column_family,
+    const char* key, size_t keylen,
+    const char* val, size_t vallen,
+    int ttl,
+    int column_family_ttl,
+    char** errptr);
+
+/* Transactions */
+
+/* Opens a database whose transactions check for conflicts when they are
+   committed. */
+extern leveldb_t* leveldb_optimistictransactiondb_open(
+    const leveldb_options_t* options,
+    const char* name,
+    char** errptr);
+
+/* Begins a transaction on a database opened with
+   leveldb_optimistictransactiondb_open.  If set_snapshot is non-zero, the
+   transaction conflicts with every write made to the keys it writes or
+   reads for update since it began, otherwise only with the writes made
+   since the keys were first written or read for update. */
+extern leveldb_transaction_t* leveldb_optimistictransaction_begin(
+    leveldb_t* db,
+    const leveldb_writeoptions_t* write_options,
+    unsigned char set_snapshot);
+
+extern void leveldb_transaction_destroy(leveldb_transaction_t* txn);
+
+extern void leveldb_transaction_commit(
+    leveldb_transaction_t* txn,
+    char** errptr);
+
+extern void leveldb_transaction_rollback(
+    leveldb_transaction_t* txn,
+    char** errptr);
+
+/* Returns the snapshot the transaction was begun with, or NULL.  It is
+   owned by the transaction. */
+extern const leveldb_snapshot_t* leveldb_transaction_get_snapshot(
+    leveldb_transaction_t* txn);
+
+extern void leveldb_transaction_snapshot_destroy(
+    const leveldb_snapshot_t* snapshot);
+
+/* Reads a key, including the writes of the transaction itself. */
+extern char* leveldb_transaction_get(
+    leveldb_transaction_t* txn,
+    const leveldb_readoptions_t* options,
+    const char* key, size_t keylen,
+    size_t* vallen,
+    char** errptr);
+
+/* Reads a key like leveldb_transaction_get and makes the transaction
+   conflict with other writes to it. */
+extern char* leveldb_transaction_get_for_update(
+    leveldb_transaction_t* txn,
+    const leveldb_readoptions_t* options,
+    const char* key, size_t keylen,
+    size_t* vallen,
+    unsigned char exclusive,
+    char** errptr);
+
+extern void leveldb_transaction_put(
+    leveldb_transaction_t* txn,
+    const char* key, size_t keylen,
+    const char* val, size_t vallen,
+    char** errptr);
+
+extern void leveldb_transaction_delete(
+    leveldb_transaction_t* txn,
+    const char* key, size_t keylen,
+    char** errptr);
+
+extern void leveldb_transaction_merge(
+    leveldb_transaction_t* txn,
+    const char* key, size_t keylen,
+    const char* val, size_t vallen,
+    char** errptr);
+
+/* Iterates over the database with the writes of the transaction applied. */
+extern leveldb_iterator_t* leveldb_transaction_create_iterator(
+    leveldb_transaction_t* txn,
+    const leveldb_readoptions_t* options);
+
+/* Opens a database whose transactions lock the keys they write or read for
+   update. */
+extern leveldb_t* leveldb_transactiondb_open(
+    const leveldb_options_t* options,
+    const leveldb_transactiondb_options_t* txn_db_options,
+    const char* name,
+    char** errptr);
+
+/* Begins a transaction on a database opened with
+   leveldb_transactiondb_open. */
+extern leveldb_transaction_t* leveldb_transaction_begin(
+    leveldb_t* db,
+    const leveldb_writeoptions_t* write_options,
+    const leveldb_transaction_options_t* txn_options);
+
+/* Returns a malloc()ed array of the transactions that were prepared but
+   neither committed nor rolled back before the database was last closed. */
+extern leveldb_transaction_t** leveldb_transactiondb_get_prepared_transactions(
+    leveldb_t* db,
+    size_t* cnt);
+
+/* Returns the number of transactions in the most recent deadlock that was
+   detected, or 0 if there was none.  The arrays are malloc()ed and so are
+   the elements of keys; the transaction at index i waits for keys[i],
+   which is held by the one at index i + 1, and the last one waits for a
+   key of the first one. */
+extern size_t leveldb_transactiondb_get_last_deadlock(
+    leveldb_t* db,
+    uint64_t** txn_ids,
+    uint32_t** column_family_ids,
+    char*** keys,
+    size_t** key_lens,
+    unsigned char** exclusive);
+
+extern uint64_t leveldb_transaction_get_id(leveldb_transaction_t* txn);
+
+extern void leveldb_transaction_set_name(
+    leveldb_transaction_t* txn,
+    const char* name, size_t namelen,
+    char** errptr);
+
+/* Returns the malloc()ed name of the transaction. */
+extern char* leveldb_transaction_get_name(
+    leveldb_transaction_t* txn,
+    size_t* namelen);
+
+/* Makes the writes of a named transaction durable without committing them,
+   the first phase of a two-phase commit. */
+extern void leveldb_transaction_prepare(
+    leveldb_transaction_t* txn,
+    char** errptr);
+
+extern void leveldb_transaction_set_savepoint(leveldb_transaction_t* txn);
+
+extern void leveldb_transaction_rollback_to_savepoint(
+    leveldb_transaction_t* txn,
+    char** errptr);
+
+/* Transaction options */
+
+extern leveldb_transactiondb_options_t* leveldb_transactiondb_options_create();
+extern void leveldb_transactiondb_options_destroy(
+    leveldb_transactiondb_options_t*);
+extern void leveldb_transactiondb_options_set_transaction_lock_timeout(
+    leveldb_transactiondb_options_t*, int64_t);
+extern void leveldb_transactiondb_options_set_default_lock_timeout(
+    leveldb_transactiondb_options_t*, int64_t);
+extern void leveldb_transactiondb_options_set_max_num_deadlocks(
+    leveldb_transactiondb_options_t*, uint32_t);
+
+extern leveldb_transaction_options_t* leveldb_transaction_options_create();
+extern void leveldb_transaction_options_destroy(
+    leveldb_transaction_options_t*);
+extern void leveldb_transaction_options_set_set_snapshot(
+    leveldb_transaction_options_t*, unsigned char);
+extern void leveldb_transaction_options_set_deadlock_detect(
+    leveldb_transaction_options_t*, unsigned char);
+extern void leveldb_transaction_options_set_lock_timeout(
+    leveldb_transaction_options_t*, int64_t);
+
 extern void leveldb_put(
     leveldb_t* db,
     const leveldb_writeoptions_t* options,
@@ -116,6 +434,23 @@ extern char* leveldb_get(
     size_t* vallen,
     char** errptr);
 
+/* Returns NULL if not found.  Otherwise the value is pinned, that is, it
+   refers to memory of the database, which is kept until the pinnable slice
+   is destroyed. */
+extern leveldb_pinnableslice_t* leveldb_get_pinned(
+    leveldb_t* db,
+    const leveldb_readoptions_t* options,
+    const char* key, size_t keylen,
+    char** errptr);
+
+extern const char* leveldb_pinnableslice_value(
+    const leveldb_pinnableslice_t* slice,
+    size_t* vallen);
+
+extern void leveldb_pinnableslice_destroy(leveldb_pinnableslice_t* slice);
+
+/* The value, length and error arrays are malloc()ed, and so are their
+   non-NULL elements. */
 extern void leveldb_multi_get(
     leveldb_t* db,
     const leveldb_readoptions_t* options,
@@ -172,7 +507,8 @@ extern void leveldb_repair_db(
 
 /* Backup */
 extern void leveldb_disable_file_deletions(
-  leveldb_t *db);
+  leveldb_t *db,
+  char** errptr);
 
 extern void leveldb_enable_file_deletions(
   leveldb_t *db);
@@ -194,12 +530,26 @@ extern unsigned char leveldb_iter_valid(const leveldb_iterator_t*);
 extern void leveldb_iter_seek_to_first(leveldb_iterator_t*);
 extern void leveldb_iter_seek_to_last(leveldb_iterator_t*);
 extern void leveldb_iter_seek(leveldb_iterator_t*, const char* k, size_t klen);
+extern void leveldb_iter_seek_for_prev(leveldb_iterator_t*, const char* k, size_t klen);
 extern void leveldb_iter_next(leveldb_iterator_t*);
 extern void leveldb_iter_prev(leveldb_iterator_t*);
 extern const char* leveldb_iter_key(const leveldb_iterator_t*, size_t* klen);
 extern const char* leveldb_iter_value(const leveldb_iterator_t*, size_t* vlen);
 extern void leveldb_iter_get_error(const leveldb_iterator_t*, char** errptr);
 
+/* Copies the keys and values of up to max_entries entries, starting at the
+   current one, into buf and moves the iterator past them. The lengths of the
+   i-th key and value are stored in lens[2*i] and lens[2*i+1]. It stops before
+   an entry that doesn't fit into buflen, and returns the number of entries
+   copied. If the first entry doesn't fit, its size is stored in *needed and
+   the iterator isn't moved. */
+extern size_t leveldb_iter_next_batch(
+    leveldb_iterator_t* iter,
+    size_t max_entries,
+    char* buf, size_t buflen,
+    size_t* lens,
+    size_t* needed);
+
 /* Write batch */
 
 extern leveldb_writebatch_t* leveldb_writebatch_create();
@@ -209,9 +559,27 @@ extern void leveldb_writebatch_put(
     leveldb_writebatch_t*,
     const char* key, size_t klen,
     const char* val, size_t vlen);
+extern void leveldb_writebatch_merge(
+    leveldb_writebatch_t*,
+    const char* key, size_t klen,
+    const char* val, size_t vlen);
 extern void leveldb_writebatch_delete(
     leveldb_writebatch_t*,
     const char* key, size_t klen);
+extern void leveldb_writebatch_put_cf(
+    leveldb_writebatch_t*,
+    leveldb_column_family_handle_t* column_family,
+    const char* key, size_t klen,
+    const char* val, size_t vlen);
+extern void leveldb_writebatch_merge_cf(
+    leveldb_writebatch_t*,
+    leveldb_column_family_handle_t* column_family,
+    const char* key, size_t klen,
+    const char* val, size_t vlen);
+extern void leveldb_writebatch_delete_cf(
+    leveldb_writebatch_t*,
+    leveldb_column_family_handle_t* column_family,
+    const char* key, size_t klen);
 extern void leveldb_writebatch_iterate(
     leveldb_writebatch_t*,
     void* state,
@@ -232,12 +600,25 @@ extern void leveldb_options_set_compression_per_level(
 extern void leveldb_options_set_filter_policy(
     leveldb_options_t*,
     leveldb_filterpolicy_t*);
+extern void leveldb_options_set_merge_operator(
+    leveldb_options_t*,
+    leveldb_mergeoperator_t*);
+extern void leveldb_options_set_compaction_filter(
+    leveldb_options_t*,
+    leveldb_compactionfilter_t*);
+extern void leveldb_options_set_prefix_extractor(
+    leveldb_options_t*,
+    leveldb_slicetransform_t*);
+extern void leveldb_options_set_memtable_prefix_bloom_size_ratio(
+    leveldb_options_t*, double);
 extern void leveldb_options_set_create_if_missing(
     leveldb_options_t*, unsigned char);
 extern void leveldb_options_set_error_if_exists(
     leveldb_options_t*, unsigned char);
 extern void leveldb_options_set_paranoid_checks(
     leveldb_options_t*, unsigned char);
+extern void leveldb_options_set_allow_2pc(
+    leveldb_options_t*, unsigned char);
 extern void leveldb_options_set_env(leveldb_options_t*, leveldb_env_t*);
 // buffer & cache
 extern void leveldb_options_set_write_buffer_size(leveldb_options_t*, size_t);
@@ -252,8 +633,6 @@ extern void leveldb_options_set_block_restart_interval(leveldb_options_t*, int);
 // sync
 extern void leveldb_options_set_use_fsync(
     leveldb_options_t*, unsigned char);
-extern void leveldb_options_set_disable_data_sync(
-    leveldb_options_t*, unsigned char);
 // log
 extern void leveldb_options_set_info_log(leveldb_options_t*, leveldb_logger_t*);
 extern void leveldb_options_set_db_log_dir(leveldb_options_t*, const char*);
@@ -272,10 +651,6 @@ extern void leveldb_options_set_max_bytes_for_level_base(
     leveldb_options_t*, uint64_t);
 extern void leveldb_options_set_max_bytes_for_level_multiplier(
     leveldb_options_t*, int);
-extern void leveldb_options_set_expanded_compaction_factor(
-    leveldb_options_t*, int);
-extern void leveldb_options_set_max_grandparent_overlap_factor(
-    leveldb_options_t*, int);
 extern void leveldb_options_set_num_levels(leveldb_options_t* opt, int);
 extern void leveldb_options_set_level0_file_num_compaction_trigger(
     leveldb_options_t*, int);
@@ -283,8 +658,6 @@ extern void leveldb_options_set_level0_slowdown_writes_trigger(
     leveldb_options_t*, int);
 extern void leveldb_options_set_level0_stop_writes_trigger(
     leveldb_options_t*, int);
-extern void leveldb_options_set_max_mem_compaction_level(
-    leveldb_options_t* opt, int);
 
 enum {
   leveldb_no_compression = 0,
@@ -324,9 +697,97 @@ extern void leveldb_filterpolicy_destroy(leveldb_filterpolicy_t*);
 extern leveldb_filterpolicy_t* leveldb_filterpolicy_create_bloom(
     int bits_per_key);
 
+/* Calls the CreateFilter and KeyMayMatch methods of a filter policy, so
+   that custom policies can delegate to the built-in bloom filter.
+   leveldb_filterpolicy_create_filter returns a malloc()ed filter. */
+extern char* leveldb_filterpolicy_create_filter(
+    leveldb_filterpolicy_t*,
+    const char* const* key_array, const size_t* key_length_array,
+    int num_keys,
+    size_t* filter_length);
+extern unsigned char leveldb_filterpolicy_key_may_match(
+    leveldb_filterpolicy_t*,
+    const char* key, size_t length,
+    const char* filter, size_t filter_length);
+
+/* Merge Operator */
+
+/* full_merge and partial_merge return a value that is released with
+   delete_value, and set *success to 0 if the operands can't be merged.
+   existing_value is NULL if the key doesn't exist.  The options take
+   ownership of the merge operator once it is set on them. */
+extern leveldb_mergeoperator_t* leveldb_mergeoperator_create(
+    void* state,
+    void (*destructor)(void*),
+    char* (*full_merge)(
+        void*,
+        const char* key, size_t key_length,
+        const char* existing_value, size_t existing_value_length,
+        const char* const* operands_list, const size_t* operands_list_length,
+        int num_operands,
+        unsigned char* success, size_t* new_value_length),
+    char* (*partial_merge)(
+        void*,
+        const char* key, size_t key_length,
+        const char* left_operand, size_t left_operand_length,
+        const char* right_operand, size_t right_operand_length,
+        unsigned char* success, size_t* new_value_length),
+    void (*delete_value)(
+        void*,
+        const char* value, size_t value_length),
+    const char* (*name)(void*));
+extern void leveldb_mergeoperator_destroy(leveldb_mergeoperator_t*);
+
+/* Compaction filter */
+
+/* filter returns 1 if the entry should be removed.  To keep the entry
+   with a different value, it sets *value_changed to 1 and *new_value to a
+   malloc()ed value.  Unlike merge operators, compaction filters are not
+   owned by the options and must outlive every database using them. */
+extern leveldb_compactionfilter_t* leveldb_compactionfilter_create(
+    void* state,
+    void (*destructor)(void*),
+    unsigned char (*filter)(
+        void*,
+        int level,
+        const char* key, size_t key_length,
+        const char* existing_value, size_t value_length,
+        char** new_value, size_t* new_value_length,
+        unsigned char* value_changed),
+    const char* (*name)(void*));
+extern void leveldb_compactionfilter_destroy(leveldb_compactionfilter_t*);
+
+/* Slice transform */
+
+/* transform returns a pointer into key, and stores the length of the
+   transformed key in *dst_length.  The options take ownership of the
+   slice transform once it is set on them. */
+extern leveldb_slicetransform_t* leveldb_slicetransform_create(
+    void* state,
+    void (*destructor)(void*),
+    char* (*transform)(
+        void*,
+        const char* key, size_t length,
+        size_t* dst_length),
+    unsigned char (*in_domain)(
+        void*,
+        const char* key, size_t length),
+    unsigned char (*in_range)(
+        void*,
+        const char* key, size_t length),
+    const char* (*name)(void*));
+extern leveldb_slicetransform_t* leveldb_slicetransform_create_fixed_prefix(
+    size_t prefix_len);
+extern leveldb_slicetransform_t* leveldb_slicetransform_create_capped_prefix(
+    size_t cap_len);
+extern void leveldb_slicetransform_destroy(leveldb_slicetransform_t*);
+
 /* Read options */
 
 extern leveldb_readoptions_t* leveldb_readoptions_create();
+/* Returns a copy of the options that has its own copies of the bounds. */
+extern leveldb_readoptions_t* leveldb_readoptions_copy(
+    const leveldb_readoptions_t*);
 extern void leveldb_readoptions_destroy(leveldb_readoptions_t*);
 extern void leveldb_readoptions_set_verify_checksums(
     leveldb_readoptions_t*,
@@ -337,10 +798,31 @@ extern void leveldb_readoptions_set_snapshot(
     leveldb_readoptions_t*,
     const leveldb_snapshot_t*);
 
+/* Limits iterators to the keys starting with prefix, which is copied. */
 extern void leveldb_readoptions_set_read_prefix(
     leveldb_readoptions_t*,
     const char* prefix,
     size_t prefix_len);
+/* Iterators stop before upper_bound, which is copied; NULL removes it. */
+extern void leveldb_readoptions_set_iterate_upper_bound(
+    leveldb_readoptions_t*,
+    const char* key, size_t keylen);
+/* Iterators stop at lower_bound, which is copied; NULL removes it. */
+extern void leveldb_readoptions_set_iterate_lower_bound(
+    leveldb_readoptions_t*,
+    const char* key, size_t keylen);
+extern void leveldb_readoptions_set_prefix_same_as_start(
+    leveldb_readoptions_t*, unsigned char);
+extern void leveldb_readoptions_set_total_order_seek(
+    leveldb_readoptions_t*, unsigned char);
+/* Reads fail with a TimedOut status after deadline_us, in microseconds
+   since the Unix epoch; 0 removes the deadline. */
+extern void leveldb_readoptions_set_deadline(
+    leveldb_readoptions_t*, uint64_t deadline_us);
+/* Single file reads fail with a TimedOut status when they take longer than
+   timeout_us microseconds; 0 removes the timeout. */
+extern void leveldb_readoptions_set_io_timeout(
+    leveldb_readoptions_t*, uint64_t timeout_us);
 
 /* Write options */
 
@@ -28,5 +28,9 @@ func NewLRUCache(capacity int) *Cache {
 
 // Close deallocates the underlying memory of the Cache object.
 func (c *Cache) Close() {
+	if c.Cache == nil {
+		return
+	}
 	C.leveldb_cache_destroy(c.Cache)
+	c.Cache = nil
 }
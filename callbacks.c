@@ -0,0 +1,209 @@
+// C glue between the RocksDB C API and the Go callbacks exported by this
+// package. Every object created here carries a Go handle as its state; the
+// static functions below only cast that state back and forward the call to
+// the matching Go function.
+
+#include <stdint.h>
+#include <stdlib.h>
+#include "rocksdb/c.h"
+#include "_cgo_export.h"
+
+//
+// Comparator
+//
+
+static void ratgo_comparator_destructor(void* state) {
+  ratgo_comparator_destroy((uintptr_t)state);
+}
+
+static int ratgo_comparator_compare_cb(
+    void* state,
+    const char* a, size_t alen,
+    const char* b, size_t blen) {
+  return ratgo_comparator_compare((uintptr_t)state,
+                                  (char*)a, alen, (char*)b, blen);
+}
+
+static const char* ratgo_comparator_name_cb(void* state) {
+  return ratgo_comparator_name((uintptr_t)state);
+}
+
+leveldb_comparator_t* ratgo_comparator_create(uintptr_t handle) {
+  return leveldb_comparator_create((void*)handle,
+                                   ratgo_comparator_destructor,
+                                   ratgo_comparator_compare_cb,
+                                   ratgo_comparator_name_cb);
+}
+
+//
+// Filter policy
+//
+
+static void ratgo_filterpolicy_destructor(void* state) {
+  ratgo_filterpolicy_destroy((uintptr_t)state);
+}
+
+static char* ratgo_filterpolicy_create_filter_cb(
+    void* state,
+    const char* const* key_array, const size_t* key_length_array,
+    int num_keys,
+    size_t* filter_length) {
+  return ratgo_filterpolicy_create_filter((uintptr_t)state,
+                                          (char**)key_array,
+                                          (size_t*)key_length_array,
+                                          num_keys, filter_length);
+}
+
+static unsigned char ratgo_filterpolicy_key_may_match_cb(
+    void* state,
+    const char* key, size_t length,
+    const char* filter, size_t filter_length) {
+  return ratgo_filterpolicy_key_may_match((uintptr_t)state,
+                                          (char*)key, length,
+                                          (char*)filter, filter_length);
+}
+
+static const char* ratgo_filterpolicy_name_cb(void* state) {
+  return ratgo_filterpolicy_name((uintptr_t)state);
+}
+
+leveldb_filterpolicy_t* ratgo_filterpolicy_create(uintptr_t handle) {
+  return leveldb_filterpolicy_create((void*)handle,
+                                     ratgo_filterpolicy_destructor,
+                                     ratgo_filterpolicy_create_filter_cb,
+                                     ratgo_filterpolicy_key_may_match_cb,
+                                     ratgo_filterpolicy_name_cb);
+}
+
+//
+// Merge operator
+//
+
+static void ratgo_mergeoperator_destructor(void* state) {
+  ratgo_mergeoperator_destroy((uintptr_t)state);
+}
+
+static char* ratgo_mergeoperator_full_merge_cb(
+    void* state,
+    const char* key, size_t key_length,
+    const char* existing_value, size_t existing_value_length,
+    const char* const* operands_list, const size_t* operands_list_length,
+    int num_operands,
+    unsigned char* success, size_t* new_value_length) {
+  return ratgo_mergeoperator_full_merge((uintptr_t)state,
+                                        (char*)key, key_length,
+                                        (char*)existing_value,
+                                        existing_value_length,
+                                        (char**)operands_list,
+                                        (size_t*)operands_list_length,
+                                        num_operands,
+                                        success, new_value_length);
+}
+
+static char* ratgo_mergeoperator_partial_merge_cb(
+    void* state,
+    const char* key, size_t key_length,
+    const char* left_operand, size_t left_operand_length,
+    const char* right_operand, size_t right_operand_length,
+    unsigned char* success, size_t* new_value_length) {
+  return ratgo_mergeoperator_partial_merge((uintptr_t)state,
+                                           (char*)key, key_length,
+                                           (char*)left_operand,
+                                           left_operand_length,
+                                           (char*)right_operand,
+                                           right_operand_length,
+                                           success, new_value_length);
+}
+
+static void ratgo_mergeoperator_delete_value_cb(
+    void* state,
+    const char* value, size_t value_length) {
+  free((void*)value);
+}
+
+static const char* ratgo_mergeoperator_name_cb(void* state) {
+  return ratgo_mergeoperator_name((uintptr_t)state);
+}
+
+leveldb_mergeoperator_t* ratgo_mergeoperator_create(uintptr_t handle) {
+  return leveldb_mergeoperator_create((void*)handle,
+                                      ratgo_mergeoperator_destructor,
+                                      ratgo_mergeoperator_full_merge_cb,
+                                      ratgo_mergeoperator_partial_merge_cb,
+                                      ratgo_mergeoperator_delete_value_cb,
+                                      ratgo_mergeoperator_name_cb);
+}
+
+//
+// Compaction filter
+//
+
+static void ratgo_compactionfilter_destructor(void* state) {
+  ratgo_compactionfilter_destroy((uintptr_t)state);
+}
+
+static unsigned char ratgo_compactionfilter_filter_cb(
+    void* state,
+    int level,
+    const char* key, size_t key_length,
+    const char* existing_value, size_t value_length,
+    char** new_value, size_t* new_value_length,
+    unsigned char* value_changed) {
+  return ratgo_compactionfilter_filter((uintptr_t)state, level,
+                                       (char*)key, key_length,
+                                       (char*)existing_value, value_length,
+                                       new_value, new_value_length,
+                                       value_changed);
+}
+
+static const char* ratgo_compactionfilter_name_cb(void* state) {
+  return ratgo_compactionfilter_name((uintptr_t)state);
+}
+
+leveldb_compactionfilter_t* ratgo_compactionfilter_create(uintptr_t handle) {
+  return leveldb_compactionfilter_create((void*)handle,
+                                         ratgo_compactionfilter_destructor,
+                                         ratgo_compactionfilter_filter_cb,
+                                         ratgo_compactionfilter_name_cb);
+}
+
+//
+// Slice transform
+//
+
+static void ratgo_slicetransform_destructor(void* state) {
+  ratgo_slicetransform_destroy((uintptr_t)state);
+}
+
+static char* ratgo_slicetransform_transform_cb(
+    void* state,
+    const char* key, size_t length,
+    size_t* dst_length) {
+  return ratgo_slicetransform_transform((uintptr_t)state,
+                                        (char*)key, length, dst_length);
+}
+
+static unsigned char ratgo_slicetransform_in_domain_cb(
+    void* state,
+    const char* key, size_t length) {
+  return ratgo_slicetransform_in_domain((uintptr_t)state, (char*)key, length);
+}
+
+static unsigned char ratgo_slicetransform_in_range_cb(
+    void* state,
+    const char* key, size_t length) {
+  return ratgo_slicetransform_in_range((uintptr_t)state, (char*)key, length);
+}
+
+static const char* ratgo_slicetransform_name_cb(void* state) {
+  return ratgo_slicetransform_name((uintptr_t)state);
+}
+
+leveldb_slicetransform_t* ratgo_slicetransform_create(uintptr_t handle) {
+  return leveldb_slicetransform_create((void*)handle,
+                                       ratgo_slicetransform_destructor,
+                                       ratgo_slicetransform_transform_cb,
+                                       ratgo_slicetransform_in_domain_cb,
+                                       ratgo_slicetransform_in_range_cb,
+                                       ratgo_slicetransform_name_cb);
+}
@@ -0,0 +1,200 @@
+package ratgo
+
+import (
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// CreateCheckpoint creates a consistent copy of the database in dir, which
+// can be opened like any other database. Writes to the DB may continue while
+// the checkpoint is created; the checkpoint contains the data as of the
+// moment the memtable was flushed at its start.
+//
+// The table files are hard linked into dir where possible, so dir should be
+// on the same filesystem as the database to keep checkpoints cheap. Files
+// that can't be linked are copied. dir must not exist yet; it is only
+// created once the checkpoint is complete.
+func (db *DB) CreateCheckpoint(dir string) error {
+	if _, err := os.Stat(dir); err == nil {
+		return &os.PathError{Op: "checkpoint", Path: dir, Err: os.ErrExist}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	// Build the checkpoint in a new directory next to dir, and only rename it
+	// into place when it is complete, so a failure never leaves a
+	// half-written checkpoint behind.
+	dir = filepath.Clean(dir)
+	tmpDir, err := os.MkdirTemp(filepath.Dir(dir), filepath.Base(dir)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	if err := os.Chmod(tmpDir, 0755); err != nil {
+		os.RemoveAll(tmpDir)
+		return err
+	}
+	if err := db.copyLiveFiles(tmpDir); err != nil {
+		os.RemoveAll(tmpDir)
+		return err
+	}
+	if err := syncDir(tmpDir); err != nil {
+		os.RemoveAll(tmpDir)
+		return err
+	}
+	if err := os.Rename(tmpDir, dir); err != nil {
+		os.RemoveAll(tmpDir)
+		return err
+	}
+	return syncDir(filepath.Dir(dir))
+}
+
+// copyLiveFiles links or copies the live files of the database into dir,
+// flushing the memtable first.
+func (db *DB) copyLiveFiles(dir string) error {
+	return db.withLiveFiles(true, func(files []liveFile, manifest string) error {
+		for _, file := range files {
+			src := filepath.Join(db.name, file.name)
+			dst := filepath.Join(dir, file.name)
+			if file.size < 0 {
+				if err := os.Link(src, dst); err == nil {
+					continue
+				}
+			}
+			if _, err := copyFile(src, dst, file.size); err != nil {
+				return err
+			}
+		}
+		return writeFileSync(filepath.Join(dir, "CURRENT"), []byte(manifest+"\n"))
+	})
+}
+
+// liveFile is one of the files that make up the state of a database.
+type liveFile struct {
+	// name is the name of the file in the database directory.
+	name string
+	// size is the number of bytes of the file that belong to the state, or
+	// -1 for immutable files, which belong to it completely.
+	size int64
+}
+
+// withLiveFiles calls fn with the files that make up the current state of
+// the database and the name of its MANIFEST, while file deletions are
+// disabled so that the files can be copied.
+//
+// If flushMemtable is true, the memtable is flushed first, otherwise the
+// write-ahead logs are part of the files. CURRENT is not, since the MANIFEST
+// it names may change once file deletions are enabled again; whoever copies
+// the files has to write it.
+func (db *DB) withLiveFiles(flushMemtable bool, fn func(files []liveFile, manifest string) error) error {
+	if err := db.DisableFileDeletions(); err != nil {
+		return err
+	}
+	defer db.EnableFileDeletions()
+
+	names, manifestFileSize, err := db.GetLiveFiles(flushMemtable)
+	if err != nil {
+		return err
+	}
+	var files []liveFile
+	var manifest string
+	for _, name := range names {
+		name = filepath.Base(name)
+		switch {
+		case name == "CURRENT":
+		case strings.HasPrefix(name, "MANIFEST-"):
+			manifest = name
+			files = append(files, liveFile{name, int64(manifestFileSize)})
+		case strings.HasPrefix(name, "OPTIONS-"):
+			// Rewritten on every option change, so it is copied rather
+			// than linked.
+			fi, err := os.Stat(filepath.Join(db.name, name))
+			if err != nil {
+				return err
+			}
+			files = append(files, liveFile{name, fi.Size()})
+		default:
+			files = append(files, liveFile{name, -1})
+		}
+	}
+
+	if !flushMemtable {
+		entries, err := os.ReadDir(db.name)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			if !strings.HasSuffix(entry.Name(), ".log") {
+				continue
+			}
+			// The last log is still being appended to, so only what was
+			// written up to now is copied.
+			fi, err := entry.Info()
+			if err != nil {
+				return err
+			}
+			files = append(files, liveFile{entry.Name(), fi.Size()})
+		}
+	}
+	return fn(files, manifest)
+}
+
+// copyFile copies the first size bytes of src to dst, or all of it if size
+// is negative, syncs dst and returns the CRC-32 checksum of what was copied.
+func copyFile(src, dst string, size int64) (uint32, error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return 0, err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return 0, err
+	}
+	crc := crc32.NewIEEE()
+	w := io.MultiWriter(out, crc)
+	if size < 0 {
+		_, err = io.Copy(w, in)
+	} else {
+		_, err = io.CopyN(w, in, size)
+	}
+	if err == nil {
+		err = out.Sync()
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	return crc.Sum32(), err
+}
+
+// writeFileSync writes data to a new file and syncs it.
+func writeFileSync(name string, data []byte) error {
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// syncDir syncs a directory, making the files created in it durable.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	err = d.Sync()
+	if closeErr := d.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
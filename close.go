@@ -0,0 +1,229 @@
+package ratgo
+
+// #cgo LDFLAGS: -lrocksdb -lrt
+// #include "rocksdb/c.h"
+import "C"
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"strings"
+	"sync/atomic"
+)
+
+// OpenHandle is a handle of a DB that wasn't closed or released: an
+// Iterator, a Snapshot, a Transaction or a ColumnFamilyHandle.
+type OpenHandle struct {
+	// Kind is "Iterator", "Snapshot", "Transaction" or "ColumnFamilyHandle".
+	Kind string
+	// CreatedAt is the file and line of the call that created it.
+	CreatedAt string
+}
+
+func (h OpenHandle) String() string {
+	return h.Kind + " created at " + h.CreatedAt
+}
+
+// OpenHandlesError is returned by DB.Close when handles of the DB were still
+// open, and by DB.CloseContext when the context ends before the handles of
+// the DB are closed. It lists the ones that the DB released itself.
+type OpenHandlesError struct {
+	Handles []OpenHandle
+	// Err is the error of the context of DB.CloseContext. It is nil for
+	// DB.Close.
+	Err error
+}
+
+func (e *OpenHandlesError) Error() string {
+	handles := make([]string, len(e.Handles))
+	for i, h := range e.Handles {
+		handles[i] = h.String()
+	}
+	msg := fmt.Sprintf("ratgo: closed the database with %d open handles", len(e.Handles))
+	if e.Err != nil {
+		msg += fmt.Sprintf(" (%v)", e.Err)
+	}
+	return msg + ": " + strings.Join(handles, ", ")
+}
+
+func (e *OpenHandlesError) Unwrap() error {
+	return e.Err
+}
+
+// child is a handle of a DB, which the DB releases if it is closed first.
+type child struct {
+	db        *DB
+	kind      string
+	createdAt string
+	// released is set once the child is closed, or the DB is closed and
+	// is about to release it.
+	released atomic.Bool
+	// release frees the C object. It is called once, after released is set.
+	// For Iterators and Snapshots, it must not refer to the handle itself,
+	// so that the DB doesn't keep the handle reachable for leak detection.
+	release func()
+}
+
+// track registers a child of the database, which was created by the caller
+// of the caller of track.
+func (db *DB) track(kind string, release func()) *child {
+	return db.trackAt(kind, callSite(2), release)
+}
+
+// trackAt registers a child of the database, which was created at createdAt.
+func (db *DB) trackAt(kind, createdAt string, release func()) *child {
+	c := &child{db: db, kind: kind, createdAt: createdAt, release: release}
+	db.mu.Lock()
+	if db.children == nil {
+		db.children = make(map[*child]struct{})
+	}
+	db.children[c] = struct{}{}
+	db.mu.Unlock()
+	return c
+}
+
+// callSite returns the file and line of the call skip frames above the
+// caller of callSite.
+func callSite(skip int) string {
+	if _, file, line, ok := runtime.Caller(skip + 1); ok {
+		return fmt.Sprintf("%s:%d", file, line)
+	}
+	return "unknown"
+}
+
+// close releases the child, unless the DB released it already.
+func (c *child) close() {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+	c.closeLocked()
+}
+
+// closeLocked is close for a caller that holds db.mu, such as the release
+// function of another child.
+func (c *child) closeLocked() {
+	db := c.db
+	if _, ok := db.children[c]; !ok {
+		return
+	}
+	delete(db.children, c)
+	c.released.Store(true)
+	c.release()
+	if len(db.children) == 0 && db.idle != nil {
+		close(db.idle)
+		db.idle = nil
+	}
+}
+
+// releaseOrder is the order in which shutdown releases the kinds of
+// children. Iterators go first, since they may belong to a Transaction or
+// iterate over a column family.
+var releaseOrder = []string{"Iterator", "Snapshot", "Transaction", "ColumnFamilyHandle"}
+
+// shutdown closes the database once db.closed is set. It marks the children
+// that are still open as released, waits for the calls into RocksDB in
+// progress to return, and then releases the children and closes the
+// database. It returns the children it released.
+func (db *DB) shutdown() []OpenHandle {
+	db.mu.Lock()
+	var children []*child
+	for _, kind := range releaseOrder {
+		for c := range db.children {
+			if c.kind == kind {
+				c.released.Store(true)
+				children = append(children, c)
+			}
+		}
+	}
+	db.children = nil
+	db.mu.Unlock()
+
+	db.drain()
+	var handles []OpenHandle
+	for _, c := range children {
+		c.release()
+		handles = append(handles, OpenHandle{Kind: c.kind, CreatedAt: c.createdAt})
+	}
+	C.leveldb_close(db.RocksDb)
+	db.RocksDb = nil
+	return handles
+}
+
+// beginChild is DB.begin for a call into a child of the database, which
+// returns ErrClosed once the child is released rather than once the
+// database is closed, so that CloseContext can wait for the child.
+func (db *DB) beginChild(c *child) error {
+	db.calls.Add(1)
+	if c.released.Load() {
+		db.end()
+		return ErrClosed
+	}
+	return nil
+}
+
+// end ends a call into RocksDB that began with DB.begin or one of its
+// variants.
+func (db *DB) end() {
+	if db.calls.Add(-1) == 0 && db.closed.Load() {
+		if drained := db.drained.Swap(nil); drained != nil {
+			close(*drained)
+		}
+	}
+}
+
+// drain waits until the calls into RocksDB in progress have returned. Since
+// db.closed is set and the children are marked as released, no new calls
+// begin; those that try to end again right away.
+func (db *DB) drain() {
+	drained := make(chan struct{})
+	db.drained.Store(&drained)
+	if db.calls.Load() == 0 {
+		return
+	}
+	<-drained
+}
+
+// OpenHandles returns the handles of the DB that are still open, in no
+// particular order.
+func (db *DB) OpenHandles() []OpenHandle {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	var handles []OpenHandle
+	for c := range db.children {
+		handles = append(handles, OpenHandle{Kind: c.kind, CreatedAt: c.createdAt})
+	}
+	return handles
+}
+
+// CloseContext closes the database like Close, but first waits until the
+// handles of the DB are closed and released. The methods of the DB and of
+// its Transactions return ErrClosed while it waits, but the Iterators can
+// still be used.
+//
+// If ctx ends first, CloseContext releases the handles that are still open
+// itself, and returns an *OpenHandlesError that lists them. It waits for the
+// calls to the Iterators in progress to return first; after that, the
+// Iterators are invalid and GetError returns ErrClosed.
+func (db *DB) CloseContext(ctx context.Context) error {
+	if db.closed.Swap(true) {
+		return nil
+	}
+	db.mu.Lock()
+	var idle chan struct{}
+	if len(db.children) != 0 {
+		idle = make(chan struct{})
+		db.idle = idle
+	}
+	db.mu.Unlock()
+
+	if idle != nil {
+		select {
+		case <-idle:
+		case <-ctx.Done():
+		}
+	}
+	if handles := db.shutdown(); len(handles) != 0 {
+		return &OpenHandlesError{Handles: handles, Err: ctx.Err()}
+	}
+	return nil
+}
@@ -0,0 +1,316 @@
+package ratgo
+
+// #cgo LDFLAGS: -lrocksdb -lrt
+// #include <stdlib.h>
+// #include "rocksdb/c.h"
+import "C"
+
+import (
+	"sync/atomic"
+	"unsafe"
+)
+
+// DefaultColumnFamilyName is the name of the column family every database
+// has. Data written with the methods that don't take a ColumnFamilyHandle
+// goes into it.
+const DefaultColumnFamilyName = "default"
+
+// ColumnFamilyHandle is a handle to a column family of a database, a
+// separate keyspace with its own options that shares the write-ahead log with
+// the other column families of the database, so a single DB.Write can
+// atomically update several of them.
+//
+// ColumnFamilyHandles are created by OpenColumnFamilies and
+// DB.CreateColumnFamily. To prevent memory leaks, Close should be called on
+// every handle before the DB is closed; DB.Close releases the ones that are
+// still open. The methods given a closed handle return ErrClosed.
+type ColumnFamilyHandle struct {
+	cf    *C.leveldb_column_family_handle_t
+	name  string
+	child *child
+	// ttl is the ttl of the column family in seconds, if the database was
+	// opened with OpenColumnFamiliesWithTTL.
+	ttl atomic.Int32
+}
+
+// Name returns the name of the column family.
+func (cf *ColumnFamilyHandle) Name() string {
+	return cf.name
+}
+
+// Close releases the handle. It doesn't drop the column family. Closing it
+// again does nothing.
+func (cf *ColumnFamilyHandle) Close() {
+	if cf.child != nil {
+		cf.child.close()
+	}
+}
+
+// release frees the C handle.
+func (cf *ColumnFamilyHandle) release() {
+	C.leveldb_column_family_handle_destroy(cf.cf)
+	cf.cf = nil
+}
+
+// newColumnFamilyHandle returns a handle of db for the column family name,
+// tracked as a child of db, created at createdAt, so that DB.Close releases
+// it.
+func (db *DB) newColumnFamilyHandle(c *C.leveldb_column_family_handle_t, name, createdAt string) *ColumnFamilyHandle {
+	cf := &ColumnFamilyHandle{cf: c, name: name}
+	cf.child = db.trackAt("ColumnFamilyHandle", createdAt, cf.release)
+	return cf
+}
+
+// OpenColumnFamilies opens a database with column families.
+//
+// Every column family of the database, including DefaultColumnFamilyName,
+// must be named in cfNames, with the options to open it with at the same
+// position in cfOpts. The handles of the column families are returned in the
+// same order. o gives the options of the database itself.
+func OpenColumnFamilies(dbName string, o *Options, cfNames []string, cfOpts []*Options) (*DB, []*ColumnFamilyHandle, error) {
+	if len(cfNames) != len(cfOpts) {
+		return nil, nil, DatabaseError("ratgo: the number of column family names and options must be the same")
+	}
+	return openColumnFamilies(dbName, cfNames, cfOpts, callSite(1),
+		func(name *C.char, num C.int, names **C.char, opts **C.leveldb_options_t, handles **C.leveldb_column_family_handle_t, errStr **C.char) *C.leveldb_t {
+			return C.leveldb_open_column_families(o.Opt, name, num, names, opts, handles, errStr)
+		})
+}
+
+// openFunc opens a database with column families in C, like
+// leveldb_open_column_families without the options of the database.
+type openFunc func(name *C.char, num C.int, names **C.char, opts **C.leveldb_options_t, handles **C.leveldb_column_family_handle_t, errStr **C.char) *C.leveldb_t
+
+// openColumnFamilies opens a database with column families by calling open
+// with C copies of its arguments, and returns the handles of the column
+// families, created at createdAt.
+func openColumnFamilies(dbName string, cfNames []string, cfOpts []*Options, createdAt string, open openFunc) (*DB, []*ColumnFamilyHandle, error) {
+	var errStr *C.char
+	rocksDbName := C.CString(dbName)
+	defer C.free(unsafe.Pointer(rocksDbName))
+
+	num := len(cfNames)
+	// One spare element keeps the arrays addressable when num is 0.
+	names := make([]*C.char, num+1)
+	opts := make([]*C.leveldb_options_t, num+1)
+	handles := make([]*C.leveldb_column_family_handle_t, num+1)
+	for i, name := range cfNames {
+		names[i] = C.CString(name)
+		defer C.free(unsafe.Pointer(names[i]))
+		opts[i] = cfOpts[i].Opt
+	}
+
+	rocksdb := open(rocksDbName, C.int(num), &names[0], &opts[0], &handles[0], &errStr)
+	if errStr != nil {
+		return nil, nil, newError(errStr)
+	}
+
+	db := &DB{RocksDb: rocksdb, name: dbName}
+	cfHandles := make([]*ColumnFamilyHandle, num)
+	for i := range cfHandles {
+		cfHandles[i] = db.newColumnFamilyHandle(handles[i], cfNames[i], createdAt)
+	}
+	return db, cfHandles, nil
+}
+
+// ListColumnFamilies returns the names of the column families of a database.
+func ListColumnFamilies(dbName string, o *Options) ([]string, error) {
+	var errStr *C.char
+	var num C.size_t
+	rocksDbName := C.CString(dbName)
+	defer C.free(unsafe.Pointer(rocksDbName))
+
+	list := C.leveldb_list_column_families(o.Opt, rocksDbName, &num, &errStr)
+	defer C.leveldb_list_column_families_destroy(list, num)
+	if errStr != nil {
+		return nil, newError(errStr)
+	}
+
+	names := make([]string, int(num))
+	for i, name := range unsafe.Slice(list, int(num)) {
+		names[i] = C.GoString(name)
+	}
+	return names, nil
+}
+
+// CreateColumnFamily creates a new column family with the options given.
+func (db *DB) CreateColumnFamily(o *Options, name string) (*ColumnFamilyHandle, error) {
+	if err := db.beginWrite("CreateColumnFamily", nil); err != nil {
+		return nil, err
+	}
+	defer db.end()
+	var errStr *C.char
+	cname := C.CString(name)
+	defer C.free(unsafe.Pointer(cname))
+
+	cf := C.leveldb_create_column_family(db.RocksDb, o.Opt, cname, &errStr)
+	if errStr != nil {
+		return nil, newError(errStr)
+	}
+	return db.newColumnFamilyHandle(cf, name, callSite(1)), nil
+}
+
+// DropColumnFamily drops a column family and all of its data. The handle
+// still has to be closed afterwards.
+func (db *DB) DropColumnFamily(cf *ColumnFamilyHandle) error {
+	if err := db.beginWrite("DropColumnFamily", nil, cf); err != nil {
+		return err
+	}
+	defer db.end()
+	var errStr *C.char
+	C.leveldb_drop_column_family(db.RocksDb, cf.cf, &errStr)
+	if errStr != nil {
+		return newError(errStr)
+	}
+	return nil
+}
+
+// PutCF writes data associated with a key to a column family.
+//
+// See DB.Put for details.
+func (db *DB) PutCF(wo *WriteOptions, cf *ColumnFamilyHandle, key, value []byte) error {
+	if err := db.beginWrite("PutCF", wo, cf); err != nil {
+		return err
+	}
+	defer db.end()
+	var errStr *C.char
+	var k, v *C.char
+	if len(key) != 0 {
+		k = (*C.char)(unsafe.Pointer(&key[0]))
+	}
+	if len(value) != 0 {
+		v = (*C.char)(unsafe.Pointer(&value[0]))
+	}
+
+	defer db.enterWrite().leave()
+	C.leveldb_put_cf(db.RocksDb, wo.Opt, cf.cf,
+		k, C.size_t(len(key)), v, C.size_t(len(value)), &errStr)
+	if errStr != nil {
+		return newError(errStr)
+	}
+	return nil
+}
+
+// GetCF returns the data associated with the key from a column family.
+//
+// See DB.Get for details.
+func (db *DB) GetCF(ro *ReadOptions, cf *ColumnFamilyHandle, key []byte) ([]byte, error) {
+	if err := db.beginRead(ro, cf); err != nil {
+		return nil, err
+	}
+	defer db.end()
+	var errStr *C.char
+	var vallen C.size_t
+	var k *C.char
+	if len(key) != 0 {
+		k = (*C.char)(unsafe.Pointer(&key[0]))
+	}
+
+	defer db.enterRead().leave()
+	value := C.leveldb_get_cf(db.RocksDb, ro.Opt, cf.cf,
+		k, C.size_t(len(key)), &vallen, &errStr)
+	if errStr != nil {
+		return nil, newError(errStr)
+	}
+
+	if value == nil {
+		return nil, nil
+	}
+
+	defer C.leveldb_free(unsafe.Pointer(value))
+	return C.GoBytes(unsafe.Pointer(value), C.int(vallen)), nil
+}
+
+// MultiGetCF returns the data associated with multiple keys, looking up
+// keys[i] in cfs[i].
+//
+// See DB.MultiGet for details.
+func (db *DB) MultiGetCF(ro *ReadOptions, cfs []*ColumnFamilyHandle, keys [][]byte) (returnValues [][]byte, returnErrors []error) {
+	if len(cfs) != len(keys) {
+		return multiGetError(len(keys), DatabaseError("ratgo: the number of column families and keys must be the same"))
+	}
+	if err := db.beginRead(ro, cfs...); err != nil {
+		return multiGetError(len(keys), err)
+	}
+	defer db.end()
+	var errsStr **C.char
+	var valueArray **C.char
+	var valueLengthArray *C.size_t
+	keyArrays := newCByteArrays(keys)
+	defer keyArrays.free()
+	cfArray := make([]*C.leveldb_column_family_handle_t, len(cfs)+1)
+	for i, cf := range cfs {
+		cfArray[i] = cf.cf
+	}
+
+	defer db.enterRead().leave()
+	C.leveldb_multi_get_cf(
+		db.RocksDb, ro.Opt, &cfArray[0], C.int(len(keys)),
+		keyArrays.ptrs, keyArrays.lens,
+		&valueArray, &valueLengthArray, &errsStr)
+	return multiGetResults(len(keys), valueArray, valueLengthArray, errsStr)
+}
+
+// DeleteCF removes the data associated with the key from a column family.
+//
+// See DB.Delete for details.
+func (db *DB) DeleteCF(wo *WriteOptions, cf *ColumnFamilyHandle, key []byte) error {
+	if err := db.beginWrite("DeleteCF", wo, cf); err != nil {
+		return err
+	}
+	defer db.end()
+	var errStr *C.char
+	var k *C.char
+	if len(key) != 0 {
+		k = (*C.char)(unsafe.Pointer(&key[0]))
+	}
+
+	defer db.enterWrite().leave()
+	C.leveldb_delete_cf(db.RocksDb, wo.Opt, cf.cf, k, C.size_t(len(key)), &errStr)
+	if errStr != nil {
+		return newError(errStr)
+	}
+	return nil
+}
+
+// MergeCF merges value into the data associated with the key in a column
+// family, using the MergeOperator of the column family's options.
+//
+// See DB.Merge for details.
+func (db *DB) MergeCF(wo *WriteOptions, cf *ColumnFamilyHandle, key, value []byte) error {
+	if err := db.beginWrite("MergeCF", wo, cf); err != nil {
+		return err
+	}
+	defer db.end()
+	var errStr *C.char
+	var k, v *C.char
+	if len(key) != 0 {
+		k = (*C.char)(unsafe.Pointer(&key[0]))
+	}
+	if len(value) != 0 {
+		v = (*C.char)(unsafe.Pointer(&value[0]))
+	}
+
+	defer db.enterWrite().leave()
+	C.leveldb_merge_cf(db.RocksDb, wo.Opt, cf.cf,
+		k, C.size_t(len(key)), v, C.size_t(len(value)), &errStr)
+	if errStr != nil {
+		return newError(errStr)
+	}
+	return nil
+}
+
+// NewIteratorCF returns an Iterator over a column family that uses the
+// ReadOptions given.
+//
+// See DB.NewIterator for details.
+func (db *DB) NewIteratorCF(ro *ReadOptions, cf *ColumnFamilyHandle) *Iterator {
+	if err := db.beginRead(ro, cf); err != nil {
+		return &Iterator{}
+	}
+	defer db.end()
+	it := &Iterator{Iter: C.leveldb_create_iterator_cf(db.RocksDb, ro.Opt, cf.cf)}
+	it.leak = trackLeak(it, "Iterator")
+	it.child = db.track("Iterator", it.releaser())
+	return it
+}
@@ -0,0 +1,68 @@
+package ratgo
+
+// #cgo LDFLAGS: -lrocksdb -lrt
+// #include <stdint.h>
+// #include <stdlib.h>
+// #include "rocksdb/c.h"
+//
+// extern leveldb_compactionfilter_t* ratgo_compactionfilter_create(uintptr_t handle);
+import "C"
+
+import (
+	"unsafe"
+)
+
+// CompactionFilter inspects the entries of a database while they are
+// compacted, which allows expired or otherwise unwanted data to be dropped
+// or rewritten without a scan-and-delete pass over the database.
+//
+// Filter is called for every key-value pair that is compacted at level. It
+// returns true to remove the entry. To keep the entry with a different value,
+// it returns false and a non-nil newValue; returning false and nil keeps the
+// entry unchanged. Merge operands are not passed to Filter.
+//
+// Filter runs during compactions, under the rules in Callbacks in the
+// package documentation.
+type CompactionFilter interface {
+	Filter(level int, key, existingValue []byte) (remove bool, newValue []byte)
+	Name() string
+}
+
+// compactionFilterState is a CompactionFilter with its name in C memory.
+type compactionFilterState struct {
+	filter CompactionFilter
+	name   *C.char
+}
+
+// newCompactionFilter wraps filter in a C leveldb_compactionfilter_t whose
+// callbacks call back into Go. The Go side is released when the C compaction
+// filter is destroyed.
+func newCompactionFilter(filter CompactionFilter) *C.leveldb_compactionfilter_t {
+	h := newHandle(&compactionFilterState{filter, C.CString(filter.Name())})
+	return C.ratgo_compactionfilter_create(C.uintptr_t(h))
+}
+
+//export ratgo_compactionfilter_filter
+func ratgo_compactionfilter_filter(h C.uintptr_t, level C.int, key *C.char, keyLength C.size_t, existingValue *C.char, valueLength C.size_t, newValue **C.char, newValueLength *C.size_t, valueChanged *C.uchar) C.uchar {
+	state := handleValue(uintptr(h)).(*compactionFilterState)
+	remove, value := state.filter.Filter(int(level),
+		charToBytes(key, keyLength), charToBytes(existingValue, valueLength))
+	if !remove && value != nil {
+		*newValue = (*C.char)(C.CBytes(value))
+		*newValueLength = C.size_t(len(value))
+		*valueChanged = boolToUchar(true)
+	}
+	return boolToUchar(remove)
+}
+
+//export ratgo_compactionfilter_name
+func ratgo_compactionfilter_name(h C.uintptr_t) *C.char {
+	return handleValue(uintptr(h)).(*compactionFilterState).name
+}
+
+//export ratgo_compactionfilter_destroy
+func ratgo_compactionfilter_destroy(h C.uintptr_t) {
+	state := handleValue(uintptr(h)).(*compactionFilterState)
+	C.free(unsafe.Pointer(state.name))
+	deleteHandle(uintptr(h))
+}
@@ -1,15 +1,61 @@
 package ratgo
 
 // #cgo LDFLAGS: -lrocksdb -lrt
+// #include <stdint.h>
+// #include <stdlib.h>
 // #include "rocksdb/c.h"
+//
+// extern leveldb_comparator_t* ratgo_comparator_create(uintptr_t handle);
 import "C"
 
-// Comparator must be used in C in your own library
+import (
+	"unsafe"
+)
 
-// DestroyComparator deallocates a *C.leveldb_comparator_t.
+// Comparator defines the total order of the keys in a database.
+//
+// Compare returns a value less than, equal to or greater than zero depending
+// on whether a is less than, equal to or greater than b.
 //
-// This is provided as a convienience to advanced users that have implemented
-// their own comparators in C in their own code.
-func DestroyComparator(cmp *C.leveldb_comparator_t) {
-	C.leveldb_comparator_destroy(cmp)
+// Name identifies the ordering. A database created with one comparator can
+// only be opened again with a comparator of the same name, so the name must
+// change whenever the ordering does.
+//
+// Compare is called back by RocksDB; see Callbacks in the package
+// documentation.
+type Comparator interface {
+	Compare(a, b []byte) int
+	Name() string
+}
+
+// comparatorState keeps the name of cmp in C memory, since RocksDB holds on
+// to the pointer Name returns.
+type comparatorState struct {
+	cmp  Comparator
+	name *C.char
+}
+
+// newComparator wraps cmp in a C leveldb_comparator_t whose callbacks call
+// back into Go. The Go side is released when the C comparator is destroyed.
+func newComparator(cmp Comparator) *C.leveldb_comparator_t {
+	h := newHandle(&comparatorState{cmp, C.CString(cmp.Name())})
+	return C.ratgo_comparator_create(C.uintptr_t(h))
+}
+
+//export ratgo_comparator_compare
+func ratgo_comparator_compare(h C.uintptr_t, a *C.char, alen C.size_t, b *C.char, blen C.size_t) C.int {
+	state := handleValue(uintptr(h)).(*comparatorState)
+	return C.int(state.cmp.Compare(charToBytes(a, alen), charToBytes(b, blen)))
+}
+
+//export ratgo_comparator_name
+func ratgo_comparator_name(h C.uintptr_t) *C.char {
+	return handleValue(uintptr(h)).(*comparatorState).name
+}
+
+//export ratgo_comparator_destroy
+func ratgo_comparator_destroy(h C.uintptr_t) {
+	state := handleValue(uintptr(h)).(*comparatorState)
+	C.free(unsafe.Pointer(state.name))
+	deleteHandle(uintptr(h))
 }
@@ -0,0 +1,160 @@
+package ratgo
+
+// #cgo LDFLAGS: -lrocksdb -lrt
+// #include "rocksdb/c.h"
+import "C"
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// The methods taking a context can't interrupt a call into RocksDB once it
+// started, so they check the context between the steps of their work, and
+// pass its deadline on to RocksDB with ReadOptions.SetDeadline and
+// ReadOptions.SetIOTimeout, on a copy of the ReadOptions given.
+//
+// Whenever the context has ended, they return its error, also if RocksDB
+// gave up first because of the deadline.
+
+// contextBatchSize is the number of keys MultiGetContext reads, and the
+// number of entries ScanContext iterates over, between checks of the
+// context.
+const contextBatchSize = 256
+
+// scanBatchBytes bounds the size of the batches of ScanContext.
+const scanBatchBytes = 1 << 20
+
+// GetContext returns the data associated with the key like DB.Get, unless
+// ctx ends first.
+func (db *DB) GetContext(ctx context.Context, ro *ReadOptions, key []byte) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	ro, done := ro.withContext(ctx, nil)
+	defer done()
+	value, err := db.Get(ro, key)
+	return value, contextError(ctx, err)
+}
+
+// MultiGetContext returns the data associated with multiple keys like
+// DB.MultiGet, unless ctx ends first. The keys are read in batches, and those
+// that weren't read when ctx ended get its error.
+//
+// Unlike those of MultiGet, the batches see the database at different times,
+// unless ro has a snapshot set.
+func (db *DB) MultiGetContext(ctx context.Context, ro *ReadOptions, keys [][]byte) (returnValues [][]byte, returnErrors []error) {
+	if err := ctx.Err(); err != nil {
+		return multiGetError(len(keys), err)
+	}
+	ro, done := ro.withContext(ctx, nil)
+	defer done()
+	returnValues = make([][]byte, 0, len(keys))
+	returnErrors = make([]error, 0, len(keys))
+	for start := 0; start < len(keys); start += contextBatchSize {
+		if err := ctx.Err(); err != nil {
+			values, errs := multiGetError(len(keys)-start, err)
+			return append(returnValues, values...), append(returnErrors, errs...)
+		}
+		end := start + contextBatchSize
+		if end > len(keys) {
+			end = len(keys)
+		}
+		values, errs := db.MultiGet(ro, keys[start:end])
+		for i := range errs {
+			errs[i] = contextError(ctx, errs[i])
+		}
+		returnValues = append(returnValues, values...)
+		returnErrors = append(returnErrors, errs...)
+	}
+	return returnValues, returnErrors
+}
+
+// WriteContext writes a WriteBatch like DB.Write, unless ctx has ended.
+// RocksDB has no deadline for writes, so the write isn't interrupted once it
+// started.
+func (db *DB) WriteContext(ctx context.Context, wo *WriteOptions, w *WriteBatch) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return db.Write(wo, w)
+}
+
+// ScanContext calls fn with the key-value pairs whose keys are at least start
+// and less than limit, in order, until fn returns an error or ctx ends, and
+// returns that error. A nil start begins at the first key, a nil limit ends
+// at the last one unless ro has an iterate upper bound. A limit that isn't
+// nil is set as the iterate upper bound of a copy of ro.
+//
+// The pairs are read in batches with Iterator.NextBatch, and ctx is checked
+// between them. The key and value are copies, so fn may keep them.
+func (db *DB) ScanContext(ctx context.Context, ro *ReadOptions, start, limit []byte, fn func(key, value []byte) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	ro, done := ro.withContext(ctx, limit)
+	defer done()
+	it := db.NewIterator(ro)
+	defer it.Close()
+
+	if start != nil {
+		it.Seek(start)
+	} else {
+		it.SeekToFirst()
+	}
+	for {
+		keys, values := it.NextBatch(contextBatchSize, scanBatchBytes)
+		if len(keys) == 0 {
+			return contextError(ctx, it.GetError())
+		}
+		for i, key := range keys {
+			if err := fn(key, values[i]); err != nil {
+				return err
+			}
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+}
+
+// withContext returns ro, or a copy of it with the deadline of ctx if it has
+// one and with upper as the iterate upper bound if it isn't nil, and a
+// function that releases the copy.
+func (ro *ReadOptions) withContext(ctx context.Context, upper []byte) (*ReadOptions, func()) {
+	deadline, ok := ctx.Deadline()
+	if !ok && upper == nil || ro.Opt == nil {
+		return ro, func() {}
+	}
+	c := &ReadOptions{Opt: C.leveldb_readoptions_copy(ro.Opt), snapshot: ro.snapshot}
+	if upper != nil {
+		c.SetIterateUpperBound(upper)
+	}
+	if !ok {
+		return c, c.Close
+	}
+	c.SetDeadline(deadline)
+	timeout := time.Until(deadline)
+	if timeout < time.Microsecond {
+		// A timeout of 0 would mean none.
+		timeout = time.Microsecond
+	}
+	c.SetIOTimeout(timeout)
+	return c, c.Close
+}
+
+// contextError returns the error of ctx instead of err if ctx has ended, or
+// if err is RocksDB giving up at the deadline of ctx.
+func contextError(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	if deadline, ok := ctx.Deadline(); ok && errors.Is(err, ErrTimedOut) && !time.Now().Before(deadline) {
+		return context.DeadlineExceeded
+	}
+	return err
+}
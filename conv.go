@@ -1,7 +1,12 @@
 package ratgo
 
+// #include <stdlib.h>
 import "C"
 
+import (
+	"unsafe"
+)
+
 func boolToUchar(b bool) C.uchar {
 	uc := C.uchar(0)
 	if b {
@@ -16,3 +21,57 @@ func ucharToBool(uc C.uchar) bool {
 	}
 	return true
 }
+
+// charToBytes returns a []byte backed by the C memory at data, without
+// copying it. The slice is only valid as long as the C memory is, so it must
+// not be kept after a callback returns.
+func charToBytes(data *C.char, n C.size_t) []byte {
+	if data == nil || n == 0 {
+		return []byte{}
+	}
+	return unsafe.Slice((*byte)(unsafe.Pointer(data)), int(n))
+}
+
+// cByteArrays holds copies of byte slices in C memory, laid out as the
+// key_array and key_length_array arguments of the C API expect them. C may
+// not be handed Go memory that holds Go pointers, so these arrays can't be
+// built in Go memory.
+type cByteArrays struct {
+	ptrs **C.char
+	lens *C.size_t
+	data *C.char
+}
+
+// newCByteArrays copies bs into C memory. The copies must be released with
+// free.
+func newCByteArrays(bs [][]byte) *cByteArrays {
+	n := len(bs)
+	size := 0
+	for _, b := range bs {
+		size += len(b)
+	}
+	// Allocate at least one element, so that empty arrays are valid
+	// pointers too.
+	a := &cByteArrays{
+		ptrs: (**C.char)(C.malloc(C.size_t(n+1) * C.size_t(unsafe.Sizeof((*C.char)(nil))))),
+		lens: (*C.size_t)(C.malloc(C.size_t(n+1) * C.size_t(unsafe.Sizeof(C.size_t(0))))),
+		data: (*C.char)(C.malloc(C.size_t(size + 1))),
+	}
+	buf := unsafe.Slice((*byte)(unsafe.Pointer(a.data)), size+1)
+	ptrs := unsafe.Slice(a.ptrs, n+1)
+	lens := unsafe.Slice(a.lens, n+1)
+	off := 0
+	for i, b := range bs {
+		copy(buf[off:], b)
+		ptrs[i] = (*C.char)(unsafe.Pointer(&buf[off]))
+		lens[i] = C.size_t(len(b))
+		off += len(b)
+	}
+	return a
+}
+
+func (a *cByteArrays) free() {
+	C.free(unsafe.Pointer(a.ptrs))
+	C.free(unsafe.Pointer(a.lens))
+	C.free(unsafe.Pointer(a.data))
+}
@@ -57,26 +57,71 @@ size_t get_list_int_at(size_t *list, int idx){
 import "C"
 
 import (
+	"sync"
+	"sync/atomic"
 	"unsafe"
 )
 
+// DatabaseError is returned for the errors ratgo finds itself, such as
+// arguments that don't fit together. The errors of RocksDB are *Error.
 type DatabaseError string
 
 func (e DatabaseError) Error() string {
 	return string(e)
 }
 
+// ReadOnlyError is returned by the methods that write to a database opened
+// with OpenForReadOnly or OpenAsSecondary. It holds the name of the method.
+type ReadOnlyError string
+
+func (e ReadOnlyError) Error() string {
+	return "ratgo: " + string(e) + " is not allowed on a read-only database"
+}
+
+// ErrClosed is returned by the methods of a DB that is closed, and by those
+// that are passed a closed Iterator, WriteBatch, ReadOptions, WriteOptions or
+// ColumnFamilyHandle, or a released Snapshot.
+var ErrClosed = DatabaseError("ratgo: use of a closed handle")
+
 // DB is a reusable handle to a LevelDB database on disk, created by Open.
 //
 // To avoid memory and file descriptor leaks, call Close when the process no
-// longer needs the handle. Calls to any DB method made after Close will
-// panic.
+// longer needs the handle. The methods called after Close return ErrClosed,
+// or do nothing if they don't return an error. Close waits for the methods
+// that are still running to return before it closes the database.
 //
 // The DB instance may be shared between goroutines. The usual data race
 // conditions will occur if the same key is written to from more than one, of
 // course.
 type DB struct {
 	RocksDb *C.leveldb_t
+
+	// name is the directory the database was opened from.
+	name string
+	// readOnly is set if the database was opened with OpenForReadOnly or
+	// OpenAsSecondary.
+	readOnly bool
+	// withTTL is set if the database was opened with a ttl, and ttl is then
+	// the ttl of its default column family in seconds.
+	withTTL bool
+	ttl     atomic.Int32
+	// closed is set by Close.
+	closed atomic.Bool
+	// calls is the number of calls into RocksDB in progress, which Close
+	// waits for. The last one to return once the DB is closed closes
+	// drained, if it is set.
+	calls   atomic.Int64
+	drained atomic.Pointer[chan struct{}]
+
+	// mu guards children, the handles of the DB that are open, and idle,
+	// which CloseContext waits on until they are closed.
+	mu       sync.Mutex
+	children map[*child]struct{}
+	idle     chan struct{}
+
+	// reads and writes limit the calls into RocksDB, if they are set.
+	reads  atomic.Pointer[limiter]
+	writes atomic.Pointer[limiter]
 }
 
 // Range is a range of keys in the database. GetApproximateSizes calls with it
@@ -94,6 +139,12 @@ type Range struct {
 // created it.
 type Snapshot struct {
 	snap *C.leveldb_snapshot_t
+	// released is set when a snapshot that isn't a child of its DB is
+	// released: that of a Transaction, or one created after the DB was
+	// closed.
+	released atomic.Bool
+	child    *child
+	leak     *leakRecord
 }
 
 // Open opens a database.
@@ -110,11 +161,68 @@ func Open(dbName string, o *Options) (*DB, error) {
 
 	rocksdb := C.leveldb_open(o.Opt, rocksDbName, &errStr)
 	if errStr != nil {
-		gs := C.GoString(errStr)
-		C.leveldb_free(unsafe.Pointer(errStr))
-		return nil, DatabaseError(gs)
+		return nil, newError(errStr)
 	}
-	return &DB{rocksdb}, nil
+	return &DB{RocksDb: rocksdb, name: dbName}, nil
+}
+
+// OpenForReadOnly opens a database that can only be read. The database is not
+// locked, so any number of processes may open it read-only, even while
+// another process has it open for writing; they only see the data as it was
+// when they opened it, though.
+//
+// The methods that write to the database return a ReadOnlyError. If
+// errorIfLogFileExists is true, opening fails if the database has writes
+// that were never flushed from the write-ahead log.
+func OpenForReadOnly(dbName string, o *Options, errorIfLogFileExists bool) (*DB, error) {
+	var errStr *C.char
+	rocksDbName := C.CString(dbName)
+	defer C.free(unsafe.Pointer(rocksDbName))
+
+	rocksdb := C.leveldb_open_for_read_only(o.Opt, rocksDbName, boolToUchar(errorIfLogFileExists), &errStr)
+	if errStr != nil {
+		return nil, newError(errStr)
+	}
+	return &DB{RocksDb: rocksdb, name: dbName, readOnly: true}, nil
+}
+
+// OpenAsSecondary opens a secondary instance of the database at primaryPath,
+// which may be open in another process. The secondary instance doesn't lock
+// the database and keeps its own files, like its information log, in
+// secondaryPath, which it creates if it doesn't exist.
+//
+// Like a database opened with OpenForReadOnly, a secondary instance can only
+// be read, but unlike it, it can follow the writes to the primary with
+// DB.TryCatchUpWithPrimary.
+func OpenAsSecondary(primaryPath, secondaryPath string, o *Options) (*DB, error) {
+	var errStr *C.char
+	rocksDbName := C.CString(primaryPath)
+	defer C.free(unsafe.Pointer(rocksDbName))
+	secondaryName := C.CString(secondaryPath)
+	defer C.free(unsafe.Pointer(secondaryName))
+
+	rocksdb := C.leveldb_open_as_secondary(o.Opt, rocksDbName, secondaryName, &errStr)
+	if errStr != nil {
+		return nil, newError(errStr)
+	}
+	return &DB{RocksDb: rocksdb, name: primaryPath, readOnly: true}, nil
+}
+
+// TryCatchUpWithPrimary makes a secondary instance opened with
+// OpenAsSecondary see the writes that were made to the primary instance of
+// the database since it was opened or last caught up, as far as the primary
+// has written them to its write-ahead log.
+func (db *DB) TryCatchUpWithPrimary() error {
+	if err := db.begin(); err != nil {
+		return err
+	}
+	defer db.end()
+	var errStr *C.char
+	C.leveldb_try_catch_up_with_primary(db.RocksDb, &errStr)
+	if errStr != nil {
+		return newError(errStr)
+	}
+	return nil
 }
 
 // DestroyDatabase removes a database entirely, removing everything from the
@@ -126,9 +234,7 @@ func DestroyDatabase(dbname string, o *Options) error {
 
 	C.leveldb_destroy_db(o.Opt, ldbname, &errStr)
 	if errStr != nil {
-		gs := C.GoString(errStr)
-		C.leveldb_free(unsafe.Pointer(errStr))
-		return DatabaseError(gs)
+		return newError(errStr)
 	}
 	return nil
 }
@@ -141,6 +247,10 @@ func DestroyDatabase(dbname string, o *Options) error {
 // The key and value byte slices may be reused safely. Put takes a copy of
 // them before returning.
 func (db *DB) Put(wo *WriteOptions, key, value []byte) error {
+	if err := db.beginWrite("Put", wo); err != nil {
+		return err
+	}
+	defer db.end()
 	var errStr *C.char
 	var k, v *C.char
 	if len(key) != 0 {
@@ -152,13 +262,12 @@ func (db *DB) Put(wo *WriteOptions, key, value []byte) error {
 
 	lenk := len(key)
 	lenv := len(value)
+	defer db.enterWrite().leave()
 	C.leveldb_put(
 		db.RocksDb, wo.Opt, k, C.size_t(lenk), v, C.size_t(lenv), &errStr)
 
 	if errStr != nil {
-		gs := C.GoString(errStr)
-		C.leveldb_free(unsafe.Pointer(errStr))
-		return DatabaseError(gs)
+		return newError(errStr)
 	}
 	return nil
 }
@@ -173,6 +282,10 @@ func (db *DB) Put(wo *WriteOptions, key, value []byte) error {
 // The key byte slice may be reused safely. Get takes a copy of
 // them before returning.
 func (db *DB) Get(ro *ReadOptions, key []byte) ([]byte, error) {
+	if err := db.beginRead(ro); err != nil {
+		return nil, err
+	}
+	defer db.end()
 	var errStr *C.char
 	var vallen C.size_t
 	var k *C.char
@@ -180,13 +293,12 @@ func (db *DB) Get(ro *ReadOptions, key []byte) ([]byte, error) {
 		k = (*C.char)(unsafe.Pointer(&key[0]))
 	}
 
+	defer db.enterRead().leave()
 	value := C.leveldb_get(
 		db.RocksDb, ro.Opt, k, C.size_t(len(key)), &vallen, &errStr)
 
 	if errStr != nil {
-		gs := C.GoString(errStr)
-		C.leveldb_free(unsafe.Pointer(errStr))
-		return nil, DatabaseError(gs)
+		return nil, newError(errStr)
 	}
 
 	if value == nil {
@@ -207,34 +319,43 @@ func (db *DB) Get(ro *ReadOptions, key []byte) ([]byte, error) {
 // The key byte slice may be reused safely. Get takes a copy of
 // them before returning.
 func (db *DB) MultiGet(ro *ReadOptions, keys [][]byte) (returnValues [][]byte, returnErrors []error) {
+	if err := db.beginRead(ro); err != nil {
+		return multiGetError(len(keys), err)
+	}
+	defer db.end()
 	var errsStr **C.char
 	var valueArray **C.char
 	var valueLengthArray *C.size_t
-	num := len(keys)
-	keyArray := make([]*C.char, num)
-	keyLengthArray := make([]C.size_t, num)
-
-	returnValues = make([][]byte, len(keys))
-	returnErrors = make([]error, len(keys))
-
-	for i, key := range keys {
-		keyArray[i] = (*C.char)(unsafe.Pointer(&key[0]))
-		// keyArray[i] = C.CString(string(key))
-		keyLengthArray[i] = C.size_t(len(key))
-	}
-
-	keyArrayPtr := &keyArray[0]
-	keyLengthArrayPtr := &keyLengthArray[0]
+	keyArrays := newCByteArrays(keys)
+	defer keyArrays.free()
 
+	defer db.enterRead().leave()
 	C.leveldb_multi_get(
 		db.RocksDb, ro.Opt, C.int(len(keys)),
-		keyArrayPtr, keyLengthArrayPtr,
+		keyArrays.ptrs, keyArrays.lens,
 		&valueArray, &valueLengthArray, &errsStr)
+	return multiGetResults(len(keys), valueArray, valueLengthArray, errsStr)
+}
+
+// multiGetError returns the results of a MultiGet that failed with err.
+func multiGetError(num int, err error) (returnValues [][]byte, returnErrors []error) {
+	returnValues = make([][]byte, num)
+	returnErrors = make([]error, num)
+	for i := range returnErrors {
+		returnErrors[i] = err
+	}
+	return
+}
+
+// multiGetResults converts the arrays returned by leveldb_multi_get and
+// leveldb_multi_get_cf, and frees them.
+func multiGetResults(num int, valueArray **C.char, valueLengthArray *C.size_t, errsStr **C.char) (returnValues [][]byte, returnErrors []error) {
+	returnValues = make([][]byte, num)
+	returnErrors = make([]error, num)
 	for i := 0; i < num; i++ {
 		errStr := C.get_list_at(errsStr, C.int(i))
 		if errStr != nil {
-			returnErrors[i] = DatabaseError(C.GoString(errStr))
-			C.leveldb_free(unsafe.Pointer(errStr))
+			returnErrors[i] = newError(errStr)
 		} else {
 			value := C.get_list_at(valueArray, C.int(i))
 			valueLength := C.get_list_int_at(valueLengthArray, C.int(i))
@@ -246,7 +367,9 @@ func (db *DB) MultiGet(ro *ReadOptions, keys [][]byte) (returnValues [][]byte, r
 			C.leveldb_free(unsafe.Pointer(value))
 		}
 	}
+	C.leveldb_free(unsafe.Pointer(valueArray))
 	C.leveldb_free(unsafe.Pointer(valueLengthArray))
+	C.leveldb_free(unsafe.Pointer(errsStr))
 	return
 }
 
@@ -256,12 +379,15 @@ func (db *DB) MultiGet(ro *ReadOptions, keys [][]byte) (returnValues [][]byte, r
 // If false, it will return immediately.
 // Default: true
 func (db *DB) Flush(fo *FlushOptions) error {
+	if err := db.beginWrite("Flush", nil); err != nil {
+		return err
+	}
+	defer db.end()
 	var errStr *C.char
+	defer db.enterWrite().leave()
 	C.leveldb_flush(db.RocksDb, fo.Opt, &errStr)
 	if errStr != nil {
-		gs := C.GoString(errStr)
-		C.leveldb_free(unsafe.Pointer(errStr))
-		return DatabaseError(gs)
+		return newError(errStr)
 	}
 	return nil
 }
@@ -272,24 +398,36 @@ func (db *DB) Flush(fo *FlushOptions) error {
 // The key byte slice may be reused safely. Delete takes a copy of
 // them before returning.
 func (db *DB) Delete(wo *WriteOptions, key []byte) error {
+	if err := db.beginWrite("Delete", wo); err != nil {
+		return err
+	}
+	defer db.end()
 	var errStr *C.char
 	var k *C.char
 	if len(key) != 0 {
 		k = (*C.char)(unsafe.Pointer(&key[0]))
 	}
 
+	defer db.enterWrite().leave()
 	C.leveldb_delete(
 		db.RocksDb, wo.Opt, k, C.size_t(len(key)), &errStr)
 
 	if errStr != nil {
-		gs := C.GoString(errStr)
-		C.leveldb_free(unsafe.Pointer(errStr))
-		return DatabaseError(gs)
+		return newError(errStr)
 	}
 	return nil
 }
 
+// Merge merges value into the data associated with the key, using the
+// MergeOperator set with Options.SetMergeOperator.
+//
+// The key and value byte slices may be reused safely. Merge takes a copy of
+// them before returning.
 func (db *DB) Merge(wo *WriteOptions, key []byte, value []byte) error {
+	if err := db.beginWrite("Merge", wo); err != nil {
+		return err
+	}
+	defer db.end()
 	var errStr *C.char
 	var k, v *C.char
 	if len(key) != 0 {
@@ -301,25 +439,33 @@ func (db *DB) Merge(wo *WriteOptions, key []byte, value []byte) error {
 
 	lenk := len(key)
 	lenv := len(value)
+	defer db.enterWrite().leave()
 	C.leveldb_merge(
 		db.RocksDb, wo.Opt, k, C.size_t(lenk), v, C.size_t(lenv), &errStr)
 
 	if errStr != nil {
-		gs := C.GoString(errStr)
-		C.leveldb_free(unsafe.Pointer(errStr))
-		return DatabaseError(gs)
+		return newError(errStr)
 	}
 	return nil
 }
 
 // Write atomically writes a WriteBatch to disk.
 func (db *DB) Write(wo *WriteOptions, w *WriteBatch) error {
+	if err := db.beginWrite("Write", wo); err != nil {
+		return err
+	}
+	defer db.end()
+	if w.wbatch == nil {
+		return ErrClosed
+	}
+	if w.err != nil {
+		return w.err
+	}
 	var errStr *C.char
+	defer db.enterWrite().leave()
 	C.leveldb_write(db.RocksDb, wo.Opt, w.wbatch, &errStr)
 	if errStr != nil {
-		gs := C.GoString(errStr)
-		C.leveldb_free(unsafe.Pointer(errStr))
-		return DatabaseError(gs)
+		return newError(errStr)
 	}
 	return nil
 }
@@ -334,9 +480,40 @@ func (db *DB) Write(wo *WriteOptions, w *WriteBatch) error {
 // before passing it here.
 //
 // Similiarly, ReadOptions.SetSnapshot is also useful.
+//
+// If the DB is closed, the Iterator returned is closed too, and its GetError
+// returns ErrClosed.
 func (db *DB) NewIterator(ro *ReadOptions) *Iterator {
-	it := C.leveldb_create_iterator(db.RocksDb, ro.Opt)
-	return &Iterator{Iter: it}
+	if err := db.beginRead(ro); err != nil {
+		return &Iterator{}
+	}
+	defer db.end()
+	it := &Iterator{Iter: C.leveldb_create_iterator(db.RocksDb, ro.Opt)}
+	it.leak = trackLeak(it, "Iterator")
+	it.child = db.track("Iterator", it.releaser())
+	return it
+}
+
+// ReverseScan calls fn with the key-value pairs whose keys are at least lower
+// and less than upper, in reverse order, until fn returns an error, and
+// returns that error or the error of the Iterator. A nil upper begins at the
+// last key, a nil lower ends at the first one, unless ro has an iterate bound
+// there. The key and value are copies, so fn may keep them.
+//
+// The range is set as the iterate bounds of a copy of ro. A bound that isn't
+// nil replaces that of ro, a nil one keeps it.
+func (db *DB) ReverseScan(ro *ReadOptions, lower, upper []byte, fn func(key, value []byte) error) error {
+	ro, done := ro.withBounds(lower, upper)
+	defer done()
+	it := db.NewIterator(ro)
+	defer it.Close()
+
+	for it.SeekToLast(); it.Valid(); it.Prev() {
+		if err := fn(it.Key(), it.Value()); err != nil {
+			return err
+		}
+	}
+	return it.GetError()
 }
 
 // GetApproximateSizes returns the approximate number of bytes of file system
@@ -345,6 +522,10 @@ func (db *DB) NewIterator(ro *ReadOptions) *Iterator {
 // The keys counted will begin at Range.Start and end on the key before
 // Range.Limit.
 func (db *DB) GetApproximateSizes(ranges []Range) []uint64 {
+	if db.begin() != nil {
+		return make([]uint64, len(ranges))
+	}
+	defer db.end()
 	starts := make([]*C.char, len(ranges))
 	limits := make([]*C.char, len(ranges))
 	startLens := make([]C.size_t, len(ranges))
@@ -377,6 +558,10 @@ func (db *DB) GetApproximateSizes(ranges []Range) []uint64 {
 // Examples of properties include "leveldb.stats", "leveldb.sstables",
 // and "leveldb.num-files-at-level0".
 func (db *DB) PropertyValue(propName string) string {
+	if db.begin() != nil {
+		return ""
+	}
+	defer db.end()
 	cname := C.CString(propName)
 	value := C.GoString(C.leveldb_property_value(db.RocksDb, cname))
 	C.free(unsafe.Pointer(cname))
@@ -393,19 +578,52 @@ func (db *DB) PropertyValue(propName string) string {
 // created it.
 //
 // See the LevelDB documentation for details.
+//
+// If the DB is closed, the snapshot returned is released already, so that
+// reads with it return ErrClosed.
 func (db *DB) NewSnapshot() *Snapshot {
-	return &Snapshot{C.leveldb_create_snapshot(db.RocksDb)}
+	snap := &Snapshot{}
+	if db.begin() != nil {
+		snap.released.Store(true)
+		return snap
+	}
+	defer db.end()
+	snap.snap = C.leveldb_create_snapshot(db.RocksDb)
+	snap.leak = trackLeak(snap, "Snapshot")
+	// The release function doesn't refer to snap, so that the DB doesn't keep
+	// it reachable for leak detection.
+	s, leak := snap.snap, snap.leak
+	snap.child = db.track("Snapshot", func() {
+		C.leveldb_release_snapshot(db.RocksDb, s)
+		untrackLeak(leak)
+	})
+	return snap
+}
+
+// isReleased reports whether the snapshot is released.
+func (snap *Snapshot) isReleased() bool {
+	if snap.child != nil {
+		return snap.child.released.Load()
+	}
+	return snap.released.Load()
 }
 
 // ReleaseSnapshot removes the snapshot from the database's list of snapshots,
-// and deallocates it.
+// and deallocates it. Releasing a snapshot again, or after the DB was
+// closed, does nothing.
 func (db *DB) ReleaseSnapshot(snap *Snapshot) {
-	C.leveldb_release_snapshot(db.RocksDb, snap.snap)
+	if snap.child != nil {
+		snap.child.close()
+	}
 }
 
 // CompactRange runs a manual compaction on the Range of keys given. This is
 // not likely to be needed for typical usage.
-func (db *DB) CompactRange(r Range) {
+func (db *DB) CompactRange(r Range) error {
+	if err := db.beginWrite("CompactRange", nil); err != nil {
+		return err
+	}
+	defer db.end()
 	var start, limit *C.char
 	if len(r.Start) != 0 {
 		start = (*C.char)(unsafe.Pointer(&r.Start[0]))
@@ -413,26 +631,111 @@ func (db *DB) CompactRange(r Range) {
 	if len(r.Limit) != 0 {
 		limit = (*C.char)(unsafe.Pointer(&r.Limit[0]))
 	}
+	defer db.enterWrite().leave()
 	C.leveldb_compact_range(
 		db.RocksDb, start, C.size_t(len(r.Start)), limit, C.size_t(len(r.Limit)))
+	return nil
 }
 
 // Close closes the database, rendering it unusable for I/O, by deallocating
 // the underlying handle.
 //
-// Any attempts to use the DB after Close is called will panic.
-func (db *DB) Close() {
-	C.leveldb_close(db.RocksDb)
+// Close waits for the methods that are still running to return. Then it
+// releases the Iterators, Snapshots, Transactions and ColumnFamilyHandles of
+// the DB that are still open, and returns an *OpenHandlesError that lists
+// them; CloseContext waits for them to be closed instead. The methods called
+// after Close return ErrClosed. Closing the DB again does nothing and returns
+// nil.
+func (db *DB) Close() error {
+	if db.closed.Swap(true) {
+		return nil
+	}
+	if handles := db.shutdown(); len(handles) != 0 {
+		return &OpenHandlesError{Handles: handles}
+	}
+	return nil
+}
+
+// begin registers a call into RocksDB, which Close waits for, or returns
+// ErrClosed if the database or one of cfs is closed. A call that began has
+// to end:
+//
+//	if err := db.begin(); err != nil {
+//		return err
+//	}
+//	defer db.end()
+func (db *DB) begin(cfs ...*ColumnFamilyHandle) error {
+	db.calls.Add(1)
+	if db.closed.Load() || !allOpen(cfs) {
+		db.end()
+		return ErrClosed
+	}
+	return nil
+}
+
+// beginRead is begin for a read with ro, which also returns ErrClosed if ro
+// is closed or the snapshot set on it is released.
+func (db *DB) beginRead(ro *ReadOptions, cfs ...*ColumnFamilyHandle) error {
+	if ro.Opt == nil || (ro.snapshot != nil && ro.snapshot.isReleased()) {
+		return ErrClosed
+	}
+	return db.begin(cfs...)
+}
+
+// beginWrite is begin for a write with wo, which may be nil. It also returns
+// ErrClosed if wo is closed, and a ReadOnlyError with the name of method if
+// the database is read-only.
+func (db *DB) beginWrite(method string, wo *WriteOptions, cfs ...*ColumnFamilyHandle) error {
+	if wo != nil && wo.Opt == nil {
+		return ErrClosed
+	}
+	if err := db.begin(cfs...); err != nil {
+		return err
+	}
+	if db.readOnly {
+		db.end()
+		return ReadOnlyError(method)
+	}
+	return nil
+}
+
+// allOpen reports whether none of cfs is closed.
+func allOpen(cfs []*ColumnFamilyHandle) bool {
+	for _, cf := range cfs {
+		if cf.cf == nil {
+			return false
+		}
+	}
+	return true
 }
 
 // DisableFiledeleteltions instructs RocksDB to not delete data files.
 // Compactions will continue to occur, but files that are not needed by the database will not be deleted.
-func (db *DB) DisableFileDeletions() {
-	C.leveldb_disable_file_deletions(db.RocksDb)
+//
+// It fails for databases opened with OpenForReadOnly, and EnableFileDeletions
+// must then not be called.
+func (db *DB) DisableFileDeletions() error {
+	if err := db.begin(); err != nil {
+		return err
+	}
+	defer db.end()
+	var errStr *C.char
+	C.leveldb_disable_file_deletions(db.RocksDb, &errStr)
+	if errStr != nil {
+		return newError(errStr)
+	}
+	return nil
 }
 
-// EnableFileDeletions instructs RocksDB to the delete data again.
+// EnableFileDeletions undoes a call to DisableFileDeletions. The calls nest:
+// data files are deleted again once every DisableFileDeletions was undone, so
+// concurrent checkpoints and backups don't re-enable deletions for each
+// other.
 func (db *DB) EnableFileDeletions() {
+	if db.begin() != nil {
+		return
+	}
+	defer db.end()
 	C.leveldb_enable_file_deletions(db.RocksDb)
 }
 
@@ -441,6 +744,10 @@ func (db *DB) EnableFileDeletions() {
 // flushMemtable indicates whether or not flush the memtable to disk.
 // When use this operation, you'd better first issue DisableFileDeletions.
 func (db *DB) GetLiveFiles(flushMemtable bool) (files []string, manifestFileSize int, err error) {
+	if err = db.begin(); err != nil {
+		return
+	}
+	defer db.end()
 	var errStr *C.char
 	var fileArray **C.char
 	var fileLengthArray *C.size_t
@@ -449,9 +756,7 @@ func (db *DB) GetLiveFiles(flushMemtable bool) (files []string, manifestFileSize
 
 	C.leveldb_get_live_files(db.RocksDb, &fileArray, &fileLengthArray, &fileNum, &retManifestSize, boolToUchar(flushMemtable), &errStr)
 	if errStr != nil {
-		gs := C.GoString(errStr)
-		C.leveldb_free(unsafe.Pointer(errStr))
-		err = DatabaseError(gs)
+		err = newError(errStr)
 		return
 	}
 	for i := 0; i < int(fileNum); i++ {
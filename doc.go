@@ -22,7 +22,11 @@ If you are advanced user, I really recommend you to see their introduction to ge
 
 == Building ==
 
-1. You'll need to clone a copy of RocksDB.
+ratgo requires RocksDB 6.x, from 6.20 on. RocksDB 7.0 removed the block-based
+filter API that the filter policies of ratgo are built on, so ratgo doesn't
+work with RocksDB 7 or later.
+
+1. You'll need to clone a copy of RocksDB, and check out a 6.x release.
 
 2. Clone a copy of ratgo, and do the following cmd to copy the c.h, and c.cc to the destination place.
 
@@ -47,6 +51,20 @@ But, suppose you put the shared LevelDB library somewhere weird like /path/to/li
 To install ratgo remotely, you'll run:
 	CGO_CFLAGS="-I/path/to/rocksdb/include" CGO_LDFLAGS="-L/path/to/rocksdb/lib" go get github.com/senarukana/ratgo
 
+== Callbacks ==
+
+Comparators, filter policies, merge operators, compaction filters and slice
+transforms written in Go are called back by RocksDB. Their methods:
+
+- are called concurrently from RocksDB's own threads, so they must be safe
+for concurrent use;
+
+- get slices that point into memory owned by RocksDB and are only valid
+during the call, so they must copy what they keep;
+
+- must not panic, since a panic can't unwind through RocksDB and crashes
+the process.
+
 == Development ==
 
 I currently use this to build a distributed database RationalDB, for more information, see:
@@ -25,5 +25,9 @@ func NewDefaultEnv() *Env {
 
 // Close deallocates the Env, freeing the underlying struct.
 func (env *Env) Close() {
+	if env.Env == nil {
+		return
+	}
 	C.leveldb_env_destroy(env.Env)
+	env.Env = nil
 }
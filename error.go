@@ -0,0 +1,117 @@
+package ratgo
+
+// #cgo LDFLAGS: -lrocksdb -lrt
+// #include <stdlib.h>
+// #include "rocksdb/c.h"
+import "C"
+
+import (
+	"unsafe"
+)
+
+// Code is the kind of an Error, which mirrors rocksdb::Status::Code.
+type Code int
+
+const (
+	CodeOK                  = Code(0)
+	CodeNotFound            = Code(1)
+	CodeCorruption          = Code(2)
+	CodeNotSupported        = Code(3)
+	CodeInvalidArgument     = Code(4)
+	CodeIOError             = Code(5)
+	CodeMergeInProgress     = Code(6)
+	CodeIncomplete          = Code(7)
+	CodeShutdownInProgress  = Code(8)
+	CodeTimedOut            = Code(9)
+	CodeAborted             = Code(10)
+	CodeBusy                = Code(11)
+	CodeExpired             = Code(12)
+	CodeTryAgain            = Code(13)
+	CodeCompactionTooLarge  = Code(14)
+	CodeColumnFamilyDropped = Code(15)
+)
+
+// SubCode refines the Code of an Error, and mirrors
+// rocksdb::Status::SubCode.
+type SubCode int
+
+const (
+	SubCodeNone                   = SubCode(0)
+	SubCodeMutexTimeout           = SubCode(1)
+	SubCodeLockTimeout            = SubCode(2)
+	SubCodeLockLimit              = SubCode(3)
+	SubCodeNoSpace                = SubCode(4)
+	SubCodeDeadlock               = SubCode(5)
+	SubCodeStaleFile              = SubCode(6)
+	SubCodeMemoryLimit            = SubCode(7)
+	SubCodeSpaceLimit             = SubCode(8)
+	SubCodePathNotFound           = SubCode(9)
+	SubCodeMergeOperandsTooLarge  = SubCode(10)
+	SubCodeManualCompactionPaused = SubCode(11)
+	SubCodeOverwritten            = SubCode(12)
+	SubCodeTxnNotPrepared         = SubCode(13)
+	SubCodeIOFenced               = SubCode(14)
+)
+
+// Error is an error returned by RocksDB. Its message is the one of the
+// rocksdb::Status it was created from.
+//
+// Error works with errors.Is: an Error matches the sentinel errors below
+// with the same Code, and, if the sentinel has a SubCode, the same SubCode.
+// For example, errors.Is(err, ErrIOError) reports whether err is any I/O
+// error, and errors.Is(err, ErrNoSpace) whether the disk is full.
+type Error struct {
+	Code    Code
+	SubCode SubCode
+	Msg     string
+}
+
+func (e *Error) Error() string {
+	return e.Msg
+}
+
+// Is reports whether target is an *Error with the Code of e and either no
+// SubCode or the SubCode of e.
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	if !ok {
+		return false
+	}
+	return t.Code == e.Code && (t.SubCode == SubCodeNone || t.SubCode == e.SubCode)
+}
+
+// The sentinel errors to match an *Error against with errors.Is.
+var (
+	ErrNotFound            = &Error{Code: CodeNotFound, Msg: "NotFound"}
+	ErrCorruption          = &Error{Code: CodeCorruption, Msg: "Corruption"}
+	ErrNotSupported        = &Error{Code: CodeNotSupported, Msg: "Not implemented"}
+	ErrInvalidArgument     = &Error{Code: CodeInvalidArgument, Msg: "Invalid argument"}
+	ErrIOError             = &Error{Code: CodeIOError, Msg: "IO error"}
+	ErrMergeInProgress     = &Error{Code: CodeMergeInProgress, Msg: "Merge in progress"}
+	ErrIncomplete          = &Error{Code: CodeIncomplete, Msg: "Result incomplete"}
+	ErrShutdownInProgress  = &Error{Code: CodeShutdownInProgress, Msg: "Shutdown in progress"}
+	ErrTimedOut            = &Error{Code: CodeTimedOut, Msg: "Operation timed out"}
+	ErrAborted             = &Error{Code: CodeAborted, Msg: "Operation aborted"}
+	ErrBusy                = &Error{Code: CodeBusy, Msg: "Resource busy"}
+	ErrExpired             = &Error{Code: CodeExpired, Msg: "Operation expired"}
+	ErrTryAgain            = &Error{Code: CodeTryAgain, Msg: "Operation failed. Try again."}
+	ErrCompactionTooLarge  = &Error{Code: CodeCompactionTooLarge, Msg: "Compaction too large"}
+	ErrColumnFamilyDropped = &Error{Code: CodeColumnFamilyDropped, Msg: "Column family dropped"}
+
+	ErrLockTimeout  = &Error{Code: CodeTimedOut, SubCode: SubCodeLockTimeout, Msg: "Operation timed out: Timeout waiting to lock key"}
+	ErrDeadlock     = &Error{Code: CodeBusy, SubCode: SubCodeDeadlock, Msg: "Resource busy: Deadlock"}
+	ErrNoSpace      = &Error{Code: CodeIOError, SubCode: SubCodeNoSpace, Msg: "IO error: No space left on device"}
+	ErrPathNotFound = &Error{Code: CodeIOError, SubCode: SubCodePathNotFound, Msg: "IO error: No such file or directory"}
+)
+
+// newError turns an error string of the C API into an *Error, and frees it.
+// SaveError in c/c.cc stores the code and the subcode of the status in the
+// first two bytes of the string, each plus one so that they aren't NUL.
+func newError(errStr *C.char) *Error {
+	gs := C.GoString(errStr)
+	C.leveldb_free(unsafe.Pointer(errStr))
+	if len(gs) < 2 {
+		return &Error{Msg: gs}
+	}
+	return &Error{Code: Code(gs[0] - 1), SubCode: SubCode(gs[1] - 1), Msg: gs[2:]}
+}
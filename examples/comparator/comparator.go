@@ -1,45 +1,40 @@
 package main
 
-/*
-#cgo LDFLAGS: -lrocksdb -lstdc++ -lbz2 -lz -lsnappy -lrt
-#include <string.h>
-#include <rocksdb/c.h>
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path"
 
-static void CmpDestroy(void* arg) { }
+	"github.com/senarukana/ratgo"
+)
 
-static int CmpCompare(void *arg, const char* a, size_t alen,
-					const char *b, size_t blen) {
-	int n = (alen < blen) ? alen : blen;
-	int r = memcmp(a,b,n);
-	if (r == 0) {
-		if (alen < blen) r = -1;
-		else if (alen > blen) r = 1;
-	}
-	return r;
-}
-
-static const char* CmpName(void* arg) {
-	return "foo";
-}
-
-static leveldb_comparator_t *CmpFooNew() {
-	return leveldb_comparator_create(NULL, CmpDestroy, CmpCompare, CmpName);
-}
-*/
-import "C"
-
-type Comparator struct {
-	Comparator *C.leveldb_comparator_t
-}
+// FooComparator orders keys bytewise, like the default comparator, but under
+// its own name.
+type FooComparator struct{}
 
-func NewFooComparator() *Comparator {
-	return &Comparator{C.CmpFooNew()}
+func (FooComparator) Compare(a, b []byte) int {
+	return bytes.Compare(a, b)
 }
 
-func (cmp *Comparator) Close() {
-	C.leveldb_comparator_destroy(cmp.Comparator)
+func (FooComparator) Name() string {
+	return "foo"
 }
 
 func main() {
-	NewFooComparator().Close()
+	opts := ratgo.NewOptions()
+	defer opts.Close()
+	opts.SetCreateIfMissing(true)
+	opts.SetComparator(FooComparator{})
+
+	dbPath, err := os.Getwd()
+	if err != nil {
+		panic(fmt.Sprintf("can't get current file path %v)", err))
+	}
+	db, err := ratgo.Open(path.Join(dbPath, "testdb"), opts)
+	if err != nil {
+		fmt.Println("create db failed", err)
+		return
+	}
+	db.Close()
 }
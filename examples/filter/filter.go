@@ -1,64 +1,49 @@
 package main
 
-// a custom Filter that ignore the trailing space
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path"
 
-/*
-#cgo LDFLAGS: -lrocksdb -lstdc++ -lbz2 -lz -lsnappy -lrt
-#include <string.h>
-#include <stdlib.h>
-#include <rocksdb/c.h>
+	"github.com/senarukana/ratgo"
+)
 
-static unsigned char fake_filter_result = 1;
+// FooPolicy is a fake filter which lets every key through. It shows the
+// methods a custom filter policy has to implement.
+type FooPolicy struct{}
 
-static void customFilterDestroy(void* arg) { }
-
-static char* customCreateFilter(void *arg,
-		const char* const* key_array,
-		const size_t* key_length_array,
-        int num_keys,
-        size_t* filter_length) {
-	*filter_length = 4;
-	char* result = malloc(4);
-	memcpy(result, "test", 4);
-	return result;
-}
-
-static unsigned char customKeyMatch (
-        void* arg,
-        const char* key, size_t length,
-        const char* filter, size_t filter_length) {
-    if (filter_length != 4) {
-		return 0;
-    }
-    if (memcmp(filter, "test", 4) != 0 ) {
-		return 0;
-    }
-    return fake_filter_result;
-}
-
-static const char* customFilterName(void* arg) {
-	return "foo";
-}
-
-static leveldb_filterpolicy_t *CustomeFilterNew() {
-	return leveldb_filterpolicy_create(NULL, customFilterDestroy,
-		customCreateFilter, customKeyMatch, customFilterName);
-}
-*/
-import "C"
-
-type FilterPolicy struct {
-	Policy *C.leveldb_filterpolicy_t
+func (FooPolicy) CreateFilter(keys [][]byte) []byte {
+	return []byte("test")
 }
 
-func NewFooPolicy() *FilterPolicy {
-	return &FilterPolicy{C.CustomeFilterNew()}
+func (FooPolicy) KeyMayMatch(key, filter []byte) bool {
+	return bytes.Equal(filter, []byte("test"))
 }
 
-func (policy *FilterPolicy) Close() {
-	C.leveldb_filterpolicy_destroy(policy.Policy)
+func (FooPolicy) Name() string {
+	return "foo"
 }
 
 func main() {
-	NewFooPolicy().Close()
+	fooPolicy := ratgo.NewFilterPolicy(FooPolicy{})
+	defer fooPolicy.Close()
+	trailingSpacePolicy := NewTrailingSpaceFilter(10)
+	defer trailingSpacePolicy.Close()
+
+	opts := ratgo.NewOptions()
+	defer opts.Close()
+	opts.SetCreateIfMissing(true)
+	opts.SetFilterPolicy(trailingSpacePolicy)
+
+	dbPath, err := os.Getwd()
+	if err != nil {
+		panic(fmt.Sprintf("can't get current file path %v)", err))
+	}
+	db, err := ratgo.Open(path.Join(dbPath, "testdb"), opts)
+	if err != nil {
+		fmt.Println("create db failed", err)
+		return
+	}
+	db.Close()
 }
@@ -1,10 +1,17 @@
 package ratgo
 
 // #cgo LDFLAGS: -lrocksdb -lrt
+// #include <stdint.h>
 // #include <stdlib.h>
 // #include "rocksdb/c.h"
+//
+// extern leveldb_filterpolicy_t* ratgo_filterpolicy_create(uintptr_t handle);
 import "C"
 
+import (
+	"unsafe"
+)
+
 // FilterPolicy is a factory type that allows the RocksDB database to create a
 // filter, such as a bloom filter, that is stored in the sstables and used by
 // DB.Get to reduce reads.
@@ -20,6 +27,35 @@ type FilterPolicy struct {
 	Policy *C.leveldb_filterpolicy_t
 }
 
+// CustomFilterPolicy is a filter policy implemented in Go. It is turned into
+// a FilterPolicy with NewFilterPolicy.
+//
+// CreateFilter builds a filter summarizing keys, and KeyMayMatch reports
+// whether key may have been one of the keys of the filter built by
+// CreateFilter. KeyMayMatch must return true if the key was in the list;
+// false positives are allowed, but should be rare.
+//
+// Name identifies the filter encoding. If it changes in an incompatible way,
+// the name must change too, otherwise old filters may be passed to the new
+// policy.
+//
+// The methods are callbacks, which follow the rules in Callbacks in the
+// package documentation.
+type CustomFilterPolicy interface {
+	CreateFilter(keys [][]byte) []byte
+	KeyMayMatch(key, filter []byte) bool
+	Name() string
+}
+
+// filterPolicyState is a CustomFilterPolicy with its name in C memory, and
+// whatever has to be released along with it.
+type filterPolicyState struct {
+	policy CustomFilterPolicy
+	name   *C.char
+	// release, if set, is called when the C filter policy is destroyed.
+	release func()
+}
+
 // NewBloomFilter creates a filter policy that will create a bloom filter when
 // necessary with the given number of bits per key.
 //
@@ -29,6 +65,128 @@ func NewBloomFilter(bitsPerKey int) *FilterPolicy {
 	return &FilterPolicy{policy}
 }
 
+// NewFilterPolicy creates a FilterPolicy that calls back into the Go policy
+// given.
+//
+// See the FilterPolicy documentation for more.
+func NewFilterPolicy(policy CustomFilterPolicy) *FilterPolicy {
+	return newFilterPolicy(&filterPolicyState{policy: policy})
+}
+
+// NewBloomFilterWithTransform creates a bloom filter policy, like
+// NewBloomFilter, that applies transform to every key before it is added to
+// the filter or looked up in it. Keys that transform to the same bytes are
+// indistinguishable for the filter, which is useful to ignore trailing spaces
+// or to filter only on a prefix of the keys.
+//
+// The name identifies the transform and must change whenever it does.
+// transform must not modify the key passed to it. It is called back by
+// RocksDB like the methods of a CustomFilterPolicy.
+func NewBloomFilterWithTransform(bitsPerKey int, name string, transform func(key []byte) []byte) *FilterPolicy {
+	bloom := NewBloomFilter(bitsPerKey)
+	return newFilterPolicy(&filterPolicyState{
+		policy:  &transformFilter{bloom, name, transform},
+		release: bloom.Close,
+	})
+}
+
+func newFilterPolicy(state *filterPolicyState) *FilterPolicy {
+	state.name = C.CString(state.policy.Name())
+	h := newHandle(state)
+	return &FilterPolicy{C.ratgo_filterpolicy_create(C.uintptr_t(h))}
+}
+
 func (fp *FilterPolicy) Close() {
+	if fp.Policy == nil {
+		return
+	}
 	C.leveldb_filterpolicy_destroy(fp.Policy)
+	fp.Policy = nil
+}
+
+// createFilter calls the CreateFilter method of the C filter policy.
+func (fp *FilterPolicy) createFilter(keys [][]byte) []byte {
+	keyArrays := newCByteArrays(keys)
+	defer keyArrays.free()
+
+	var filterLen C.size_t
+	filter := C.leveldb_filterpolicy_create_filter(
+		fp.Policy, keyArrays.ptrs, keyArrays.lens, C.int(len(keys)), &filterLen)
+	defer C.leveldb_free(unsafe.Pointer(filter))
+	return C.GoBytes(unsafe.Pointer(filter), C.int(filterLen))
+}
+
+// keyMayMatch calls the KeyMayMatch method of the C filter policy.
+func (fp *FilterPolicy) keyMayMatch(key, filter []byte) bool {
+	var k, f *C.char
+	if len(key) != 0 {
+		k = (*C.char)(unsafe.Pointer(&key[0]))
+	}
+	if len(filter) != 0 {
+		f = (*C.char)(unsafe.Pointer(&filter[0]))
+	}
+	return ucharToBool(C.leveldb_filterpolicy_key_may_match(
+		fp.Policy, k, C.size_t(len(key)), f, C.size_t(len(filter))))
+}
+
+// transformFilter is the CustomFilterPolicy behind
+// NewBloomFilterWithTransform.
+type transformFilter struct {
+	bloom     *FilterPolicy
+	name      string
+	transform func(key []byte) []byte
+}
+
+func (tf *transformFilter) CreateFilter(keys [][]byte) []byte {
+	transformed := make([][]byte, len(keys))
+	for i, key := range keys {
+		transformed[i] = tf.transform(key)
+	}
+	return tf.bloom.createFilter(transformed)
+}
+
+func (tf *transformFilter) KeyMayMatch(key, filter []byte) bool {
+	return tf.bloom.keyMayMatch(tf.transform(key), filter)
+}
+
+func (tf *transformFilter) Name() string {
+	return tf.name
+}
+
+//export ratgo_filterpolicy_create_filter
+func ratgo_filterpolicy_create_filter(h C.uintptr_t, keyArray **C.char, keyLengthArray *C.size_t, numKeys C.int, filterLength *C.size_t) *C.char {
+	state := handleValue(uintptr(h)).(*filterPolicyState)
+	n := int(numKeys)
+	keys := make([][]byte, n)
+	if n > 0 {
+		ptrs := unsafe.Slice(keyArray, n)
+		lens := unsafe.Slice(keyLengthArray, n)
+		for i := range keys {
+			keys[i] = charToBytes(ptrs[i], lens[i])
+		}
+	}
+	filter := state.policy.CreateFilter(keys)
+	*filterLength = C.size_t(len(filter))
+	return (*C.char)(C.CBytes(filter))
+}
+
+//export ratgo_filterpolicy_key_may_match
+func ratgo_filterpolicy_key_may_match(h C.uintptr_t, key *C.char, length C.size_t, filter *C.char, filterLength C.size_t) C.uchar {
+	state := handleValue(uintptr(h)).(*filterPolicyState)
+	return boolToUchar(state.policy.KeyMayMatch(charToBytes(key, length), charToBytes(filter, filterLength)))
+}
+
+//export ratgo_filterpolicy_name
+func ratgo_filterpolicy_name(h C.uintptr_t) *C.char {
+	return handleValue(uintptr(h)).(*filterPolicyState).name
+}
+
+//export ratgo_filterpolicy_destroy
+func ratgo_filterpolicy_destroy(h C.uintptr_t) {
+	state := handleValue(uintptr(h)).(*filterPolicyState)
+	C.free(unsafe.Pointer(state.name))
+	if state.release != nil {
+		state.release()
+	}
+	deleteHandle(uintptr(h))
 }
@@ -0,0 +1,45 @@
+package ratgo
+
+import (
+	"sync"
+)
+
+// C code can't hold on to Go pointers, so Go values that are called back
+// from RocksDB (comparators, filter policies and so on) are kept in this
+// table and handed to C as an integer handle which is passed back as the
+// state argument of every callback.
+var handles = struct {
+	sync.RWMutex
+	next   uintptr
+	values map[uintptr]interface{}
+}{values: make(map[uintptr]interface{})}
+
+// newHandle registers v and returns the handle that identifies it. The
+// handle is never zero.
+func newHandle(v interface{}) uintptr {
+	handles.Lock()
+	defer handles.Unlock()
+	handles.next++
+	handles.values[handles.next] = v
+	return handles.next
+}
+
+// handleValue returns the value registered under h. It panics if h is not
+// registered, which means C called back into an object that was destroyed.
+func handleValue(h uintptr) interface{} {
+	handles.RLock()
+	v, ok := handles.values[h]
+	handles.RUnlock()
+	if !ok {
+		panic("ratgo: callback into a released handle")
+	}
+	return v
+}
+
+// deleteHandle removes h from the table, allowing the value to be garbage
+// collected.
+func deleteHandle(h uintptr) {
+	handles.Lock()
+	delete(handles.values, h)
+	handles.Unlock()
+}
@@ -0,0 +1,142 @@
+//go:build go1.23
+
+package ratgo
+
+import (
+	"context"
+	"errors"
+	"iter"
+)
+
+// All returns an iterator over all the key-value pairs of the database, in
+// order, for use with range:
+//
+//	all, errFn := db.All(ro)
+//	for key, value := range all {
+//		...
+//	}
+//	if err := errFn(); err != nil {
+//		...
+//	}
+//
+// The underlying Iterator is created when the loop starts and closed when it
+// ends, also if it ends early. The error function returns the error the
+// Iterator had, if any, once the loop has ended. The key and value are
+// copies, so they may be kept after the loop body.
+func (db *DB) All(ro *ReadOptions) (iter.Seq2[[]byte, []byte], func() error) {
+	return db.scan(ro, nil, nil, (*Iterator).SeekToFirst, (*Iterator).Next)
+}
+
+// Range returns an iterator over the key-value pairs whose keys are at least
+// start and less than limit, in order. A nil start begins at the first key,
+// a nil limit ends at the last one, unless ro has an iterate bound there.
+//
+// The range is set as the iterate bounds of a copy of ro, like that of
+// DB.ReverseScan, so RocksDB compares the keys with the Comparator of the
+// database.
+//
+// See DB.All for details.
+func (db *DB) Range(ro *ReadOptions, start, limit []byte) (iter.Seq2[[]byte, []byte], func() error) {
+	return db.scan(ro, start, limit, (*Iterator).SeekToFirst, (*Iterator).Next)
+}
+
+// Prefix returns an iterator over the key-value pairs whose keys start with
+// prefix, in order.
+//
+// The keys from prefix up to the first key after all those starting with it
+// in bytewise order are set as the iterate bounds of a copy of ro, like
+// ReadOptions.SetReadPrefix does.
+//
+// See DB.All for details.
+func (db *DB) Prefix(ro *ReadOptions, prefix []byte) (iter.Seq2[[]byte, []byte], func() error) {
+	return db.scan(ro, prefix, prefixEnd(prefix), (*Iterator).SeekToFirst, (*Iterator).Next)
+}
+
+// prefixEnd returns the first key after all those starting with prefix, which
+// is prefix with its last byte that isn't 0xff incremented and the bytes
+// after it dropped, or nil if every key from prefix on starts with it.
+func prefixEnd(prefix []byte) []byte {
+	for i := len(prefix) - 1; i >= 0; i-- {
+		if prefix[i] != 0xff {
+			end := append([]byte(nil), prefix[:i+1]...)
+			end[i]++
+			return end
+		}
+	}
+	return nil
+}
+
+// Reverse returns an iterator over all the key-value pairs of the database,
+// in reverse order.
+//
+// See DB.All for details.
+func (db *DB) Reverse(ro *ReadOptions) (iter.Seq2[[]byte, []byte], func() error) {
+	return db.scan(ro, nil, nil, (*Iterator).SeekToLast, (*Iterator).Prev)
+}
+
+// ReverseRange returns an iterator over the key-value pairs whose keys are at
+// least lower and less than upper, in reverse order, like DB.ReverseScan. A
+// nil upper begins at the last key, a nil lower ends at the first one, unless
+// ro has an iterate bound there.
+//
+// See DB.All for details.
+func (db *DB) ReverseRange(ro *ReadOptions, lower, upper []byte) (iter.Seq2[[]byte, []byte], func() error) {
+	return scanSeq(func(fn func(key, value []byte) error) error {
+		return db.ReverseScan(ro, lower, upper, fn)
+	})
+}
+
+// RangeContext returns an iterator over the key-value pairs whose keys are at
+// least start and less than limit, like Range, that reads them with
+// DB.ScanContext. The loop ends when ctx ends, and the error function then
+// returns the error of ctx.
+//
+// See DB.All for details.
+func (db *DB) RangeContext(ctx context.Context, ro *ReadOptions, start, limit []byte) (iter.Seq2[[]byte, []byte], func() error) {
+	return scanSeq(func(fn func(key, value []byte) error) error {
+		return db.ScanContext(ctx, ro, start, limit, fn)
+	})
+}
+
+// scanSeq returns an iterator over the key-value pairs scan calls its
+// function with, and a function that returns the error of scan.
+func scanSeq(scan func(fn func(key, value []byte) error) error) (iter.Seq2[[]byte, []byte], func() error) {
+	var err error
+	seq := func(yield func(key, value []byte) bool) {
+		err = scan(func(key, value []byte) error {
+			if !yield(key, value) {
+				return errStopScan
+			}
+			return nil
+		})
+		if err == errStopScan {
+			err = nil
+		}
+	}
+	return seq, func() error { return err }
+}
+
+// errStopScan ends the scan of scanSeq when the loop body breaks.
+var errStopScan = errors.New("ratgo: stop scan")
+
+// scan returns an iterator that positions an Iterator with seek and moves it
+// with next until it becomes invalid. The Iterator reads with a copy of ro
+// with the iterate bounds lower and upper, where they are not nil.
+func (db *DB) scan(ro *ReadOptions, lower, upper []byte, seek, next func(it *Iterator)) (iter.Seq2[[]byte, []byte], func() error) {
+	var err error
+	seq := func(yield func(key, value []byte) bool) {
+		ro, done := ro.withBounds(lower, upper)
+		defer done()
+		it := db.NewIterator(ro)
+		defer func() {
+			err = it.GetError()
+			it.Close()
+		}()
+		for seek(it); it.Valid(); next(it) {
+			if !yield(it.Key(), it.Value()) {
+				return
+			}
+		}
+	}
+	return seq, func() error { return err }
+}
@@ -0,0 +1,83 @@
+//go:build go1.23
+
+package ratgo
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestRangeOverFunc(t *testing.T) {
+	db, wo, ro := openTestDB(t, "testdb_range_over_func")
+	for _, k := range []string{"a", "b1", "b2", "c", "d"} {
+		if err := db.Put(wo, []byte(k), []byte("v"+k)); err != nil {
+			t.Fatalf("put key:%s failed, err %v\n", k, err)
+		}
+	}
+
+	collect := func(seq func(func([]byte, []byte) bool), errFn func() error) string {
+		var pairs []string
+		for k, v := range seq {
+			pairs = append(pairs, string(k)+"="+string(v))
+		}
+		if err := errFn(); err != nil {
+			t.Errorf("iteration failed, err %v", err)
+		}
+		return fmt.Sprint(pairs)
+	}
+
+	if got := collect(db.All(ro)); got != "[a=va b1=vb1 b2=vb2 c=vc d=vd]" {
+		t.Errorf("All returned %s", got)
+	}
+	if got := collect(db.Range(ro, []byte("b"), []byte("c"))); got != "[b1=vb1 b2=vb2]" {
+		t.Errorf("Range returned %s", got)
+	}
+	if got := collect(db.Range(ro, nil, []byte("b2"))); got != "[a=va b1=vb1]" {
+		t.Errorf("Range without start returned %s", got)
+	}
+	if got := collect(db.Prefix(ro, []byte("b"))); got != "[b1=vb1 b2=vb2]" {
+		t.Errorf("Prefix returned %s", got)
+	}
+	if end := prefixEnd([]byte("a\xff\xff")); string(end) != "b" || prefixEnd([]byte("\xff")) != nil {
+		t.Errorf("the keys with the prefix a\\xff\\xff should end at b, got %q", end)
+	}
+	if got := collect(db.Reverse(ro)); got != "[d=vd c=vc b2=vb2 b1=vb1 a=va]" {
+		t.Errorf("Reverse returned %s", got)
+	}
+	if got := collect(db.ReverseRange(ro, []byte("b"), []byte("c"))); got != "[b2=vb2 b1=vb1]" {
+		t.Errorf("ReverseRange returned %s", got)
+	}
+	if got := collect(db.ReverseRange(ro, []byte("b2"), []byte("bz"))); got != "[b2=vb2]" {
+		t.Errorf("ReverseRange between keys returned %s", got)
+	}
+	if got := collect(db.ReverseRange(ro, nil, nil)); got != "[d=vd c=vc b2=vb2 b1=vb1 a=va]" {
+		t.Errorf("ReverseRange without bounds returned %s", got)
+	}
+
+	all, errFn := db.All(ro)
+	n := 0
+	for range all {
+		if n++; n == 2 {
+			break
+		}
+	}
+	if n != 2 || errFn() != nil {
+		t.Errorf("breaking out of the loop should stop the iteration, got %d keys (%v)", n, errFn())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if got := collect(db.RangeContext(ctx, ro, []byte("b"), []byte("c"))); got != "[b1=vb1 b2=vb2]" {
+		t.Errorf("RangeContext returned %s", got)
+	}
+	cancel()
+	seq, errFn := db.RangeContext(ctx, ro, nil, nil)
+	for range seq {
+		t.Error("RangeContext shouldn't iterate with a canceled context")
+	}
+	if err := errFn(); err != context.Canceled {
+		t.Errorf("RangeContext should fail with context.Canceled, got %v", err)
+	}
+}
@@ -9,6 +9,9 @@ import (
 	"unsafe"
 )
 
+// IteratorError was returned by Iterator.GetError.
+//
+// Deprecated: Iterator.GetError returns an *Error.
 type IteratorError string
 
 func (e IteratorError) Error() string {
@@ -43,14 +46,23 @@ func (e IteratorError) Error() string {
 // 	}
 //
 // To prevent memory leaks, an Iterator must have Close called on it when it
-// is no longer needed by the program.
+// is no longer needed by the program. A closed Iterator is never valid, its
+// methods do nothing and GetError returns ErrClosed.
 type Iterator struct {
 	Iter *C.leveldb_iterator_t
+	// child is set if the Iterator belongs to a DB, which closes it when it
+	// is closed first.
+	child *child
+	leak  *leakRecord
 }
 
 // Valid returns false only when an Iterator has iterated past either the
-// first or the last key in the database.
+// first or the last key in the database, or is closed.
 func (it *Iterator) Valid() bool {
+	if !it.begin() {
+		return false
+	}
+	defer it.end()
 	return ucharToBool(C.leveldb_iter_valid(it.Iter))
 }
 
@@ -58,6 +70,10 @@ func (it *Iterator) Valid() bool {
 //
 // If Valid returns false, this method will panic.
 func (it *Iterator) Key() []byte {
+	if !it.begin() {
+		return nil
+	}
+	defer it.end()
 	var klen C.size_t
 	kdata := C.leveldb_iter_key(it.Iter, &klen)
 	if kdata == nil {
@@ -75,6 +91,10 @@ func (it *Iterator) Key() []byte {
 //
 // If Valid returns false, this method will panic.
 func (it *Iterator) Value() []byte {
+	if !it.begin() {
+		return nil
+	}
+	defer it.end()
 	var vlen C.size_t
 	vdata := C.leveldb_iter_value(it.Iter, &vlen)
 	if vdata == nil {
@@ -92,14 +112,76 @@ func (it *Iterator) Value() []byte {
 //
 // If Valid returns false, this method will panic.
 func (it *Iterator) Next() {
+	if !it.begin() {
+		return
+	}
+	defer it.end()
+	defer it.enterRead().leave()
 	C.leveldb_iter_next(it.Iter)
 }
 
+// NextBatch returns copies of the keys and values of up to maxEntries
+// entries, starting at the one the iterator currently holds, and moves the
+// iterator past them. It stops early before an entry that would make the
+// keys and values add up to more than maxBytes, but always returns the
+// current entry if the iterator is valid, however large it is. It returns
+// no entries once Valid returns false.
+//
+// A batch costs a single cgo call instead of the four per entry of Valid,
+// Key, Value and Next, which makes scans faster. The keys and values share
+// a buffer of maxBytes that is allocated by every call. As with Next,
+// GetError should be checked when the iterator is no longer valid.
+func (it *Iterator) NextBatch(maxEntries, maxBytes int) (keys, values [][]byte) {
+	if maxEntries <= 0 || !it.begin() {
+		return nil, nil
+	}
+	defer it.end()
+	if maxBytes < 0 {
+		maxBytes = 0
+	}
+	lens := make([]C.size_t, 2*maxEntries)
+	buf := make([]byte, maxBytes)
+	var needed C.size_t
+	n := it.nextBatch(maxEntries, buf, lens, &needed)
+	if n == 0 && needed > 0 {
+		buf = make([]byte, needed)
+		n = it.nextBatch(1, buf, lens, &needed)
+	}
+
+	keys = make([][]byte, n)
+	values = make([][]byte, n)
+	off := 0
+	for i := 0; i < n; i++ {
+		klen, vlen := int(lens[2*i]), int(lens[2*i+1])
+		keys[i] = buf[off : off+klen : off+klen]
+		off += klen
+		values[i] = buf[off : off+vlen : off+vlen]
+		off += vlen
+	}
+	return keys, values
+}
+
+func (it *Iterator) nextBatch(maxEntries int, buf []byte, lens []C.size_t, needed *C.size_t) int {
+	var b *C.char
+	if len(buf) != 0 {
+		b = (*C.char)(unsafe.Pointer(&buf[0]))
+	}
+	defer it.enterRead().leave()
+	n := C.leveldb_iter_next_batch(it.Iter, C.size_t(maxEntries),
+		b, C.size_t(len(buf)), &lens[0], needed)
+	return int(n)
+}
+
 // Prev moves the iterator to the previous sequential key in the database, as
 // defined by the Comparator in the ReadOptions used to create this Iterator.
 //
 // If Valid returns false, this method will panic.
 func (it *Iterator) Prev() {
+	if !it.begin() {
+		return
+	}
+	defer it.end()
+	defer it.enterRead().leave()
 	C.leveldb_iter_prev(it.Iter)
 }
 
@@ -108,6 +190,11 @@ func (it *Iterator) Prev() {
 //
 // This method is safe to call when Valid returns false.
 func (it *Iterator) SeekToFirst() {
+	if !it.begin() {
+		return
+	}
+	defer it.end()
+	defer it.enterRead().leave()
 	C.leveldb_iter_seek_to_first(it.Iter)
 }
 
@@ -116,6 +203,11 @@ func (it *Iterator) SeekToFirst() {
 //
 // This method is safe to call when Valid returns false.
 func (it *Iterator) SeekToLast() {
+	if !it.begin() {
+		return
+	}
+	defer it.end()
+	defer it.enterRead().leave()
 	C.leveldb_iter_seek_to_last(it.Iter)
 }
 
@@ -125,26 +217,104 @@ func (it *Iterator) SeekToLast() {
 //
 // This method is safe to call when Valid returns false.
 func (it *Iterator) Seek(key []byte) {
-	C.leveldb_iter_seek(it.Iter, (*C.char)(unsafe.Pointer(&key[0])), C.size_t(len(key)))
+	if !it.begin() {
+		return
+	}
+	defer it.end()
+	var k *C.char
+	if len(key) != 0 {
+		k = (*C.char)(unsafe.Pointer(&key[0]))
+	}
+	defer it.enterRead().leave()
+	C.leveldb_iter_seek(it.Iter, k, C.size_t(len(key)))
 }
 
-// GetError returns an IteratorError from LevelDB if it had one during
-// iteration.
+// SeekForPrev moves the iterator the position of the key given or, if the
+// key doesn't exist, the previous key that does exist in the database. If
+// the key doesn't exist, and there is no previous key, the Iterator becomes
+// invalid.
+//
+// This method is safe to call when Valid returns false.
+func (it *Iterator) SeekForPrev(key []byte) {
+	if !it.begin() {
+		return
+	}
+	defer it.end()
+	var k *C.char
+	if len(key) != 0 {
+		k = (*C.char)(unsafe.Pointer(&key[0]))
+	}
+	defer it.enterRead().leave()
+	C.leveldb_iter_seek_for_prev(it.Iter, k, C.size_t(len(key)))
+}
+
+// GetError returns an *Error from LevelDB if it had one during iteration.
 //
 // This method is safe to call when Valid returns false.
 func (it *Iterator) GetError() error {
+	if !it.begin() {
+		return ErrClosed
+	}
+	defer it.end()
 	var errStr *C.char
 	C.leveldb_iter_get_error(it.Iter, &errStr)
 	if errStr != nil {
-		gs := C.GoString(errStr)
-		C.free(unsafe.Pointer(errStr))
-		return IteratorError(gs)
+		return newError(errStr)
 	}
 	return nil
 }
 
 // Close deallocates the given Iterator, freeing the underlying C struct.
+// Closing it again, or after its DB was closed, does nothing.
 func (it *Iterator) Close() {
-	C.leveldb_iter_destroy(it.Iter)
+	if it.Iter == nil {
+		return
+	}
+	if it.child != nil {
+		it.child.close()
+	} else {
+		it.releaser()()
+	}
 	it.Iter = nil
 }
+
+// begin reports whether the Iterator is open, and if it belongs to a DB,
+// registers a call into it with DB.beginChild, so that the DB doesn't
+// release the Iterator before the call returns. It is used as:
+//
+//	if !it.begin() {
+//		return
+//	}
+//	defer it.end()
+func (it *Iterator) begin() bool {
+	if it.Iter == nil {
+		return false
+	}
+	return it.child == nil || it.child.db.beginChild(it.child) == nil
+}
+
+// end ends a call that began with begin.
+func (it *Iterator) end() {
+	if it.child != nil {
+		it.child.db.end()
+	}
+}
+
+// enterRead is DB.enterRead for the DB of the Iterator.
+func (it *Iterator) enterRead() *limiter {
+	if it.child == nil {
+		return nil
+	}
+	return it.child.db.enterRead()
+}
+
+// releaser returns a function that frees the C iterator. It doesn't refer to
+// the Iterator, so that a DB that keeps it doesn't keep the Iterator
+// reachable for leak detection.
+func (it *Iterator) releaser() func() {
+	iter, leak := it.Iter, it.leak
+	return func() {
+		C.leveldb_iter_destroy(iter)
+		untrackLeak(leak)
+	}
+}
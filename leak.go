@@ -0,0 +1,125 @@
+package ratgo
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Leak is an Iterator, Snapshot, Options, ReadOptions or WriteOptions that
+// was created while leak detection was enabled and wasn't closed.
+type Leak struct {
+	// Kind is the name of the type that leaked, like "Iterator".
+	Kind string
+	// Stack is the stack trace of the goroutine that created it.
+	Stack string
+	// Collected is set if the garbage collector found it unreachable, so
+	// that it can't be closed anymore. Otherwise, it is still reachable and
+	// may just not have been closed yet.
+	Collected bool
+}
+
+func (l Leak) String() string {
+	state := "not closed yet"
+	if l.Collected {
+		state = "garbage collected without being closed"
+	}
+	return fmt.Sprintf("ratgo: %s %s, created at:\n%s", l.Kind, state, l.Stack)
+}
+
+var leaks struct {
+	enabled atomic.Bool
+
+	mu   sync.Mutex
+	open map[*leakRecord]struct{}
+	// collected are the leaks the garbage collector found.
+	collected []Leak
+}
+
+// SetLeakDetection turns leak detection on or off. While it is on, the
+// Iterators, Snapshots, Options, ReadOptions and WriteOptions that are
+// created record where they were created, and Leaks reports those that
+// weren't closed. This costs a stack trace per object, so it is meant for
+// tests and debugging.
+//
+// Turning leak detection off doesn't forget the objects created while it
+// was on.
+func SetLeakDetection(enabled bool) {
+	leaks.enabled.Store(enabled)
+}
+
+// Leaks returns the objects created while leak detection was enabled that
+// weren't closed: first those the garbage collector found since the last
+// call, then those that are still reachable. Calling runtime.GC before
+// Leaks makes it find the unreachable ones.
+func Leaks() []Leak {
+	leaks.mu.Lock()
+	defer leaks.mu.Unlock()
+	result := leaks.collected
+	leaks.collected = nil
+	for r := range leaks.open {
+		result = append(result, Leak{Kind: r.kind, Stack: r.stack})
+	}
+	return result
+}
+
+// leakRecord tracks an object created while leak detection was enabled.
+type leakRecord struct {
+	kind  string
+	stack string
+}
+
+// trackLeak records obj, which is of type kind, if leak detection is
+// enabled. The record must be passed to untrackLeak when obj is closed.
+func trackLeak(obj any, kind string) *leakRecord {
+	if !leaks.enabled.Load() {
+		return nil
+	}
+	r := &leakRecord{kind: kind, stack: callers(3)}
+	leaks.mu.Lock()
+	if leaks.open == nil {
+		leaks.open = make(map[*leakRecord]struct{})
+	}
+	leaks.open[r] = struct{}{}
+	leaks.mu.Unlock()
+
+	// The finalizer only refers to the record, so that obj can be collected.
+	runtime.SetFinalizer(obj, func(any) {
+		leaks.mu.Lock()
+		defer leaks.mu.Unlock()
+		if _, ok := leaks.open[r]; ok {
+			delete(leaks.open, r)
+			leaks.collected = append(leaks.collected, Leak{Kind: r.kind, Stack: r.stack, Collected: true})
+		}
+	})
+	return r
+}
+
+// untrackLeak forgets r, which is nil if leak detection was disabled.
+func untrackLeak(r *leakRecord) {
+	if r == nil {
+		return
+	}
+	leaks.mu.Lock()
+	delete(leaks.open, r)
+	leaks.mu.Unlock()
+}
+
+// callers formats the stack of the calling goroutine, skipping skip frames
+// like runtime.Callers.
+func callers(skip int) string {
+	pcs := make([]uintptr, 32)
+	n := runtime.Callers(skip+1, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	var b strings.Builder
+	for {
+		frame, more := frames.Next()
+		fmt.Fprintf(&b, "%s\n\t%s:%d\n", frame.Function, frame.File, frame.Line)
+		if !more {
+			break
+		}
+	}
+	return b.String()
+}
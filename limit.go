@@ -0,0 +1,117 @@
+package ratgo
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// ConcurrencyStats are the statistics of the reads or the writes of a DB
+// whose concurrency is limited with DB.SetConcurrencyLimits.
+type ConcurrencyStats struct {
+	// Limit is the maximum number of calls into RocksDB at once.
+	Limit int
+	// InFlight is the number of calls into RocksDB now.
+	InFlight int64
+	// Waiting is the number of calls that wait for another one to return.
+	Waiting int64
+	// Waits is the number of calls that had to wait, and WaitTime the total
+	// time they waited.
+	Waits    uint64
+	WaitTime time.Duration
+}
+
+// SetConcurrencyLimits limits the number of reads and writes of the DB that
+// call into RocksDB at once to maxReads and maxWrites. Every call into
+// RocksDB that blocks, on a slow disk for example, keeps an OS thread busy,
+// so without limits a stall can make the process start thousands of
+// threads. With them, the calls over the limit wait in Go instead. A limit
+// of 0 or less removes it, which is the default.
+//
+// Reads are DB.Get, DB.GetCF, DB.GetSlice, DB.MultiGet, DB.MultiGetCF and
+// the methods that move the Iterators of the DB. Writes are DB.Put,
+// DB.Delete, DB.Merge, DB.Write, their variants for column families and
+// ttls, DB.Flush and DB.CompactRange.
+//
+// The calls that already entered RocksDB when the limits change count
+// against the old limits. Changing a limit resets its statistics.
+func (db *DB) SetConcurrencyLimits(maxReads, maxWrites int) {
+	db.reads.Store(newLimiter(maxReads))
+	db.writes.Store(newLimiter(maxWrites))
+}
+
+// ConcurrencyStats returns the statistics of the reads and writes of the DB.
+// They are zero if there is no limit.
+func (db *DB) ConcurrencyStats() (reads, writes ConcurrencyStats) {
+	return db.reads.Load().stats(), db.writes.Load().stats()
+}
+
+// enterRead blocks until a read may call into RocksDB, and returns the
+// limiter to leave when it returns, which is nil if reads aren't limited.
+// It is used as:
+//
+//	defer db.enterRead().leave()
+//
+// which enters right away and leaves when the method returns.
+func (db *DB) enterRead() *limiter {
+	return db.reads.Load().enter()
+}
+
+// enterWrite is enterRead for writes.
+func (db *DB) enterWrite() *limiter {
+	return db.writes.Load().enter()
+}
+
+// limiter is a semaphore that counts how long calls wait for it.
+type limiter struct {
+	slots    chan struct{}
+	inFlight atomic.Int64
+	waiting  atomic.Int64
+	waits    atomic.Uint64
+	waitTime atomic.Int64
+}
+
+func newLimiter(limit int) *limiter {
+	if limit <= 0 {
+		return nil
+	}
+	return &limiter{slots: make(chan struct{}, limit)}
+}
+
+func (l *limiter) enter() *limiter {
+	if l == nil {
+		return nil
+	}
+	select {
+	case l.slots <- struct{}{}:
+	default:
+		l.waiting.Add(1)
+		start := time.Now()
+		l.slots <- struct{}{}
+		l.waitTime.Add(int64(time.Since(start)))
+		l.waits.Add(1)
+		l.waiting.Add(-1)
+	}
+	l.inFlight.Add(1)
+	return l
+}
+
+func (l *limiter) leave() {
+	if l == nil {
+		return
+	}
+	l.inFlight.Add(-1)
+	<-l.slots
+}
+
+func (l *limiter) stats() ConcurrencyStats {
+	if l == nil {
+		return ConcurrencyStats{}
+	}
+	return ConcurrencyStats{
+		Limit:    cap(l.slots),
+		InFlight: l.inFlight.Load(),
+		Waiting:  l.waiting.Load(),
+		Waits:    l.waits.Load(),
+		WaitTime: time.Duration(l.waitTime.Load()),
+	}
+}
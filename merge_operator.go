@@ -0,0 +1,98 @@
+package ratgo
+
+// #cgo LDFLAGS: -lrocksdb -lrt
+// #include <stdint.h>
+// #include <stdlib.h>
+// #include "rocksdb/c.h"
+//
+// extern leveldb_mergeoperator_t* ratgo_mergeoperator_create(uintptr_t handle);
+import "C"
+
+import (
+	"unsafe"
+)
+
+// MergeOperator combines the values written with DB.Merge into the value of
+// a key, which makes read-modify-write operations such as counters possible
+// without a Get before every write.
+//
+// FullMerge is called with the existing value of key, which is nil if the
+// key doesn't exist, and the operands merged into it since, oldest first. It
+// returns the new value of the key.
+//
+// PartialMerge combines two operands, left being the older one, into a
+// single operand. It should return false if the operands can't be combined
+// without the existing value; RocksDB then keeps both and passes them to
+// FullMerge later.
+//
+// Both methods return false if the merge failed, which surfaces as a
+// corruption error to the reader. Name identifies the operator, and a
+// database written with one operator must be opened with an operator of the
+// same name.
+//
+// Merges run during reads, flushes and compactions; see Callbacks in the
+// package documentation for what that requires of the methods.
+type MergeOperator interface {
+	FullMerge(key, existingValue []byte, operands [][]byte) ([]byte, bool)
+	PartialMerge(key, leftOperand, rightOperand []byte) ([]byte, bool)
+	Name() string
+}
+
+// mergeOperatorState is a MergeOperator with its name in C memory.
+type mergeOperatorState struct {
+	op   MergeOperator
+	name *C.char
+}
+
+// newMergeOperator wraps op in a C leveldb_mergeoperator_t whose callbacks
+// call back into Go. The Go side is released when the C merge operator is
+// destroyed.
+func newMergeOperator(op MergeOperator) *C.leveldb_mergeoperator_t {
+	h := newHandle(&mergeOperatorState{op, C.CString(op.Name())})
+	return C.ratgo_mergeoperator_create(C.uintptr_t(h))
+}
+
+//export ratgo_mergeoperator_full_merge
+func ratgo_mergeoperator_full_merge(h C.uintptr_t, key *C.char, keyLength C.size_t, existingValue *C.char, existingValueLength C.size_t, operandsList **C.char, operandsListLength *C.size_t, numOperands C.int, success *C.uchar, newValueLength *C.size_t) *C.char {
+	state := handleValue(uintptr(h)).(*mergeOperatorState)
+	var existing []byte
+	if existingValue != nil {
+		existing = charToBytes(existingValue, existingValueLength)
+	}
+	n := int(numOperands)
+	operands := make([][]byte, n)
+	if n > 0 {
+		ptrs := unsafe.Slice(operandsList, n)
+		lens := unsafe.Slice(operandsListLength, n)
+		for i := range operands {
+			operands[i] = charToBytes(ptrs[i], lens[i])
+		}
+	}
+	newValue, ok := state.op.FullMerge(charToBytes(key, keyLength), existing, operands)
+	*success = boolToUchar(ok)
+	*newValueLength = C.size_t(len(newValue))
+	return (*C.char)(C.CBytes(newValue))
+}
+
+//export ratgo_mergeoperator_partial_merge
+func ratgo_mergeoperator_partial_merge(h C.uintptr_t, key *C.char, keyLength C.size_t, leftOperand *C.char, leftOperandLength C.size_t, rightOperand *C.char, rightOperandLength C.size_t, success *C.uchar, newValueLength *C.size_t) *C.char {
+	state := handleValue(uintptr(h)).(*mergeOperatorState)
+	newValue, ok := state.op.PartialMerge(charToBytes(key, keyLength),
+		charToBytes(leftOperand, leftOperandLength),
+		charToBytes(rightOperand, rightOperandLength))
+	*success = boolToUchar(ok)
+	*newValueLength = C.size_t(len(newValue))
+	return (*C.char)(C.CBytes(newValue))
+}
+
+//export ratgo_mergeoperator_name
+func ratgo_mergeoperator_name(h C.uintptr_t) *C.char {
+	return handleValue(uintptr(h)).(*mergeOperatorState).name
+}
+
+//export ratgo_mergeoperator_destroy
+func ratgo_mergeoperator_destroy(h C.uintptr_t) {
+	state := handleValue(uintptr(h)).(*mergeOperatorState)
+	C.free(unsafe.Pointer(state.name))
+	deleteHandle(uintptr(h))
+}
@@ -0,0 +1,165 @@
+package ratgo
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// This file contains ready-made MergeOperators for the common cases. Set one
+// with Options.SetMergeOperator and write operands with DB.Merge or
+// WriteBatch.Merge, e.g. an atomic counter:
+//
+//	opts.SetMergeOperator(ratgo.NewUint64AddOperator())
+//	...
+//	db.Merge(wo, key, ratgo.EncodeUint64(1))
+//
+// The integer operators fail the merge, which surfaces as a corruption error
+// to the reader, if a value or an operand is not 8 bytes long.
+
+// EncodeUint64 encodes v as the 8 byte little-endian value used by the
+// Uint64AddOperator.
+func EncodeUint64(v uint64) []byte {
+	b := make([]byte, 8)
+	binary.LittleEndian.PutUint64(b, v)
+	return b
+}
+
+// DecodeUint64 decodes a value encoded with EncodeUint64.
+func DecodeUint64(b []byte) (uint64, error) {
+	if len(b) != 8 {
+		return 0, fmt.Errorf("ratgo: %d bytes can't be decoded as an uint64", len(b))
+	}
+	return binary.LittleEndian.Uint64(b), nil
+}
+
+// EncodeInt64 encodes v as the 8 byte little-endian value used by the
+// Int64MaxOperator and Int64MinOperator.
+func EncodeInt64(v int64) []byte {
+	return EncodeUint64(uint64(v))
+}
+
+// DecodeInt64 decodes a value encoded with EncodeInt64.
+func DecodeInt64(b []byte) (int64, error) {
+	v, err := DecodeUint64(b)
+	return int64(v), err
+}
+
+// associativeOperator is a MergeOperator for operators where the existing
+// value and the operands have the same form, and any two of them can be
+// combined with merge.
+type associativeOperator struct {
+	name  string
+	merge func(left, right []byte) ([]byte, bool)
+}
+
+func (op *associativeOperator) FullMerge(key, existingValue []byte, operands [][]byte) ([]byte, bool) {
+	result := existingValue
+	for _, operand := range operands {
+		if result == nil {
+			result = operand
+			continue
+		}
+		var ok bool
+		if result, ok = op.merge(result, operand); !ok {
+			return nil, false
+		}
+	}
+	// The result may still be one of the arguments, which are only valid
+	// during the call.
+	return append([]byte{}, result...), true
+}
+
+func (op *associativeOperator) PartialMerge(key, leftOperand, rightOperand []byte) ([]byte, bool) {
+	return op.merge(leftOperand, rightOperand)
+}
+
+func (op *associativeOperator) Name() string {
+	return op.name
+}
+
+// NewUint64AddOperator returns a MergeOperator that treats the values and the
+// operands as uint64s encoded with EncodeUint64, and adds them up. Overflows
+// wrap around.
+//
+// Its name matches the UInt64AddOperator shipped with RocksDB, which uses the
+// same encoding.
+func NewUint64AddOperator() MergeOperator {
+	return &associativeOperator{"UInt64AddOperator", func(left, right []byte) ([]byte, bool) {
+		l, err := DecodeUint64(left)
+		if err != nil {
+			return nil, false
+		}
+		r, err := DecodeUint64(right)
+		if err != nil {
+			return nil, false
+		}
+		return EncodeUint64(l + r), true
+	}}
+}
+
+// NewStringAppendOperator returns a MergeOperator that appends the operands
+// to the value, separated by delimiter.
+//
+// Its name matches the StringAppendOperator shipped with RocksDB.
+func NewStringAppendOperator(delimiter []byte) MergeOperator {
+	delimiter = append([]byte{}, delimiter...)
+	return &associativeOperator{"StringAppendOperator", func(left, right []byte) ([]byte, bool) {
+		result := make([]byte, 0, len(left)+len(delimiter)+len(right))
+		result = append(result, left...)
+		result = append(result, delimiter...)
+		return append(result, right...), true
+	}}
+}
+
+func int64Operator(name string, pick func(l, r int64) int64) MergeOperator {
+	return &associativeOperator{name, func(left, right []byte) ([]byte, bool) {
+		l, err := DecodeInt64(left)
+		if err != nil {
+			return nil, false
+		}
+		r, err := DecodeInt64(right)
+		if err != nil {
+			return nil, false
+		}
+		return EncodeInt64(pick(l, r)), true
+	}}
+}
+
+// NewInt64MaxOperator returns a MergeOperator that treats the values and the
+// operands as int64s encoded with EncodeInt64, and keeps the largest.
+func NewInt64MaxOperator() MergeOperator {
+	return int64Operator("ratgo.Int64MaxOperator", func(l, r int64) int64 {
+		if l > r {
+			return l
+		}
+		return r
+	})
+}
+
+// NewInt64MinOperator returns a MergeOperator that treats the values and the
+// operands as int64s encoded with EncodeInt64, and keeps the smallest.
+func NewInt64MinOperator() MergeOperator {
+	return int64Operator("ratgo.Int64MinOperator", func(l, r int64) int64 {
+		if l < r {
+			return l
+		}
+		return r
+	})
+}
+
+// NewBitwiseOrOperator returns a MergeOperator that ORs the operands into the
+// value byte by byte, which turns a value into a bit set that operands add
+// members to. The shorter of two arguments is treated as if it was padded
+// with zero bytes.
+func NewBitwiseOrOperator() MergeOperator {
+	return &associativeOperator{"ratgo.BitwiseOrOperator", func(left, right []byte) ([]byte, bool) {
+		if len(left) < len(right) {
+			left, right = right, left
+		}
+		result := append([]byte{}, left...)
+		for i, b := range right {
+			result[i] |= b
+		}
+		return result, true
+	}}
+}
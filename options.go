@@ -4,6 +4,11 @@ package ratgo
 // #include "rocksdb/c.h"
 import "C"
 
+import (
+	"time"
+	"unsafe"
+)
+
 // CompressionOpt is a value for Options.SetCompression.
 type CompressionOpt int
 
@@ -23,6 +28,11 @@ const (
 // program no longer needs it.
 type Options struct {
 	Opt *C.leveldb_options_t
+
+	comparator       *C.leveldb_comparator_t
+	compactionFilter *C.leveldb_compactionfilter_t
+
+	leak *leakRecord
 }
 
 // ReadOptions represent all of the available options when reading from a
@@ -32,6 +42,11 @@ type Options struct {
 // program no longer needs it.
 type ReadOptions struct {
 	Opt *C.leveldb_readoptions_t
+
+	// snapshot is the Snapshot set with SetSnapshot, which reads check for
+	// being released.
+	snapshot *Snapshot
+	leak     *leakRecord
 }
 
 // WriteOptions represent all of the available options when writeing from a
@@ -41,6 +56,8 @@ type ReadOptions struct {
 // program no longer needs it.
 type WriteOptions struct {
 	Opt *C.leveldb_writeoptions_t
+
+	leak *leakRecord
 }
 
 // Options that control the flush operations
@@ -51,27 +68,77 @@ type FlushOptions struct {
 	Opt *C.leveldb_flushoptions_t
 }
 
+// TransactionDBOptions represent the options of a database opened with
+// OpenTransactionDB.
+//
+// To prevent memory leaks, Close must be called on a TransactionDBOptions
+// when the program no longer needs it.
+type TransactionDBOptions struct {
+	Opt *C.leveldb_transactiondb_options_t
+}
+
+// TransactionOptions represent the options of a transaction begun with
+// TransactionDB.BeginTransaction.
+//
+// To prevent memory leaks, Close must be called on a TransactionOptions when
+// the program no longer needs it.
+type TransactionOptions struct {
+	Opt *C.leveldb_transaction_options_t
+}
+
 // NewOptions allocates a new Options object.
 func NewOptions() *Options {
-	opt := C.leveldb_options_create()
-	return &Options{opt}
+	o := &Options{Opt: C.leveldb_options_create()}
+	o.leak = trackLeak(o, "Options")
+	return o
 }
 
 // NewReadOptions allocates a new ReadOptions object.
 func NewReadOptions() *ReadOptions {
-	opt := C.leveldb_readoptions_create()
-	return &ReadOptions{opt}
+	ro := &ReadOptions{Opt: C.leveldb_readoptions_create()}
+	ro.leak = trackLeak(ro, "ReadOptions")
+	return ro
 }
 
 // NewWriteOptions allocates a new WriteOptions object.
 func NewWriteOptions() *WriteOptions {
-	opt := C.leveldb_writeoptions_create()
-	return &WriteOptions{opt}
+	wo := &WriteOptions{Opt: C.leveldb_writeoptions_create()}
+	wo.leak = trackLeak(wo, "WriteOptions")
+	return wo
+}
+
+// NewTransactionDBOptions allocates a new TransactionDBOptions object.
+func NewTransactionDBOptions() *TransactionDBOptions {
+	opt := C.leveldb_transactiondb_options_create()
+	return &TransactionDBOptions{opt}
+}
+
+// NewTransactionOptions allocates a new TransactionOptions object.
+func NewTransactionOptions() *TransactionOptions {
+	opt := C.leveldb_transaction_options_create()
+	return &TransactionOptions{opt}
 }
 
 // Close deallocates the Options, freeing its underlying C struct.
+//
+// If a Comparator or a CompactionFilter was set, it is released as well, so
+// the Options must not be closed while a database opened with them is still
+// open. Closing the Options again does nothing.
 func (o *Options) Close() {
+	if o.Opt == nil {
+		return
+	}
 	C.leveldb_options_destroy(o.Opt)
+	o.Opt = nil
+	untrackLeak(o.leak)
+	if o.comparator != nil {
+		C.leveldb_comparator_destroy(o.comparator)
+		o.comparator = nil
+	}
+	if o.compactionFilter != nil {
+		C.leveldb_compactionfilter_destroy(o.compactionFilter)
+		o.compactionFilter = nil
+	}
 }
 
 // SetComparator sets the comparator to be used for all read and write
@@ -81,9 +148,17 @@ func (o *Options) Close() {
 // one with the same name string) that is used to perform read and write
 // operations.
 //
-// The default comparator is usually sufficient.
-func (o *Options) SetComparator(cmp *C.leveldb_comparator_t) {
-	C.leveldb_options_set_comparator(o.Opt, cmp)
+// The default comparator is usually sufficient. Passing nil restores it.
+func (o *Options) SetComparator(cmp Comparator) {
+	var c *C.leveldb_comparator_t
+	if cmp != nil {
+		c = newComparator(cmp)
+	}
+	C.leveldb_options_set_comparator(o.Opt, c)
+	if o.comparator != nil {
+		C.leveldb_comparator_destroy(o.comparator)
+	}
+	o.comparator = c
 }
 
 // SetErrorIfExists, if passed true, will cause the opening of a database that
@@ -121,15 +196,11 @@ func (o *Options) SetUseFsync(fsync bool) {
 	C.leveldb_options_set_use_fsync(o.Opt, boolToUchar(fsync))
 }
 
-// SetDisableDataSync determince whether or not sync data to disk.
-// If true, then the contents of data files are not synced
-// to stable storage. Their contents remain in the OS buffers till the
-// OS decides to flush them. This option is good for bulk-loading
-// of data. Once the bulk-loading is complete, please issue a
-// sync to the OS to flush all dirty buffesrs to stable storage.
-// Default: false
+// SetDisableDataSync used to determine whether or not to sync data files to
+// disk.
+//
+// Deprecated: RocksDB removed this option, so it has no effect.
 func (o *Options) SetDisableDataSync(fsync bool) {
-	C.leveldb_options_set_disable_data_sync(o.Opt, boolToUchar(fsync))
 }
 
 // SetWriteBufferSize sets the number of bytes the database will build up in
@@ -201,9 +272,75 @@ func (o *Options) SetFilterPolicy(fp *FilterPolicy) {
 	C.leveldb_options_set_filter_policy(o.Opt, policy)
 }
 
-// Close deallocates the ReadOptions, freeing its underlying C struct.
+// SetMergeOperator sets the merge operator used to combine the values written
+// with DB.Merge. Without one, every Merge fails.
+//
+// The merge operator that wrote a database must be the same one (technically,
+// one with the same name string) that is used when it is opened again.
+// Passing nil removes the merge operator.
+func (o *Options) SetMergeOperator(op MergeOperator) {
+	var m *C.leveldb_mergeoperator_t
+	if op != nil {
+		m = newMergeOperator(op)
+	}
+	// The options own the merge operator, and release the previous one.
+	C.leveldb_options_set_merge_operator(o.Opt, m)
+}
+
+// SetCompactionFilter sets the filter that is applied to every entry of the
+// database while it is compacted. Passing nil removes the filter.
+func (o *Options) SetCompactionFilter(filter CompactionFilter) {
+	var f *C.leveldb_compactionfilter_t
+	if filter != nil {
+		f = newCompactionFilter(filter)
+	}
+	C.leveldb_options_set_compaction_filter(o.Opt, f)
+	if o.compactionFilter != nil {
+		C.leveldb_compactionfilter_destroy(o.compactionFilter)
+	}
+	o.compactionFilter = f
+}
+
+// SetPrefixExtractor sets the SliceTransform that extracts the prefixes of
+// the keys. With a prefix extractor, the filters of SetFilterPolicy hold the
+// prefixes of the keys as well, so that iterators skip the tables without
+// the prefix they seek to, and ReadOptions.SetPrefixSameAsStart can be used.
+//
+// The options take ownership of the prefix extractor, and release the
+// previous one. Passing nil removes the prefix extractor.
+func (o *Options) SetPrefixExtractor(st SliceTransform) {
+	var t *C.leveldb_slicetransform_t
+	if st != nil {
+		t = newSliceTransform(st)
+	}
+	C.leveldb_options_set_prefix_extractor(o.Opt, t)
+}
+
+// SetMemtablePrefixBloomSizeRatio sets the size of the bloom filter of the
+// prefixes of the keys in the memtable, as a ratio of the write buffer size.
+// It requires a prefix extractor. It defaults to 0, which disables the
+// filter.
+func (o *Options) SetMemtablePrefixBloomSizeRatio(ratio float64) {
+	C.leveldb_options_set_memtable_prefix_bloom_size_ratio(o.Opt, C.double(ratio))
+}
+
+// SetAllow2PC enables the two-phase commit of transactions with
+// Transaction.Prepare, which requires the write-ahead log to keep the
+// prepared transactions until they are committed. It defaults to false.
+func (o *Options) SetAllow2PC(b bool) {
+	C.leveldb_options_set_allow_2pc(o.Opt, boolToUchar(b))
+}
+
+// Close deallocates the ReadOptions, freeing its underlying C struct. The
+// methods of the DB return ErrClosed for a closed ReadOptions. Closing it
+// again does nothing.
 func (ro *ReadOptions) Close() {
+	if ro.Opt == nil {
+		return
+	}
 	C.leveldb_readoptions_destroy(ro.Opt)
+	ro.Opt = nil
+	untrackLeak(ro.leak)
 }
 
 // SetVerifyChecksums controls whether all data read with this ReadOptions
@@ -237,11 +374,121 @@ func (ro *ReadOptions) SetSnapshot(snap *Snapshot) {
 		s = snap.snap
 	}
 	C.leveldb_readoptions_set_snapshot(ro.Opt, s)
+	ro.snapshot = snap
+}
+
+// SetReadPrefix limits iterators to the keys starting with prefix. Unlike
+// SetPrefixSameAsStart, it doesn't need a prefix extractor. The prefix is
+// copied, so it may be reused safely.
+//
+// SetReadPrefix sets both iterate bounds, replacing those of
+// SetIterateLowerBound and SetIterateUpperBound.
+func (ro *ReadOptions) SetReadPrefix(prefix []byte) {
+	var p *C.char
+	if len(prefix) != 0 {
+		p = (*C.char)(unsafe.Pointer(&prefix[0]))
+	}
+	C.leveldb_readoptions_set_read_prefix(ro.Opt, p, C.size_t(len(prefix)))
+}
+
+// SetIterateUpperBound makes iterators stop before key: Valid returns false
+// once the keys are not less than it, and RocksDB doesn't read the tables
+// after it. A nil key removes the bound. The key is copied, so it may be
+// reused safely. Every iterator keeps its own copy of the bounds, so the
+// ReadOptions may be changed or closed while its iterators are open.
+//
+// SetReadPrefix replaces the bound.
+func (ro *ReadOptions) SetIterateUpperBound(key []byte) {
+	ro.setIterateBound(key, true)
+}
+
+// SetIterateLowerBound makes iterators stop at key when they move backwards:
+// Valid returns false once the keys are less than it, and seeks to smaller
+// keys start at it. A nil key removes the bound.
+//
+// See SetIterateUpperBound for details.
+func (ro *ReadOptions) SetIterateLowerBound(key []byte) {
+	ro.setIterateBound(key, false)
+}
+
+func (ro *ReadOptions) setIterateBound(key []byte, upper bool) {
+	var k *C.char
+	if key != nil {
+		// An empty key still has to be a non-NULL pointer.
+		var empty C.char
+		k = &empty
+		if len(key) != 0 {
+			k = (*C.char)(unsafe.Pointer(&key[0]))
+		}
+	}
+	if upper {
+		C.leveldb_readoptions_set_iterate_upper_bound(ro.Opt, k, C.size_t(len(key)))
+	} else {
+		C.leveldb_readoptions_set_iterate_lower_bound(ro.Opt, k, C.size_t(len(key)))
+	}
+}
+
+// withBounds returns a copy of ro with the iterate bounds lower and upper,
+// where they are not nil, and a function that releases the copy.
+func (ro *ReadOptions) withBounds(lower, upper []byte) (*ReadOptions, func()) {
+	if ro.Opt == nil {
+		return ro, func() {}
+	}
+	c := &ReadOptions{Opt: C.leveldb_readoptions_copy(ro.Opt), snapshot: ro.snapshot}
+	if lower != nil {
+		c.SetIterateLowerBound(lower)
+	}
+	if upper != nil {
+		c.SetIterateUpperBound(upper)
+	}
+	return c, c.Close
+}
+
+// SetPrefixSameAsStart makes iterators stop at the end of the prefix of the
+// key they were seeked to, as extracted by Options.SetPrefixExtractor, so
+// that Valid returns false once the keys have another prefix. It defaults to
+// false.
+func (ro *ReadOptions) SetPrefixSameAsStart(b bool) {
+	C.leveldb_readoptions_set_prefix_same_as_start(ro.Opt, boolToUchar(b))
+}
+
+// SetTotalOrderSeek makes iterators ignore the prefix extractor, and thereby
+// the prefix bloom filters, so that they iterate over all the keys in order.
+// It defaults to false.
+func (ro *ReadOptions) SetTotalOrderSeek(b bool) {
+	C.leveldb_readoptions_set_total_order_seek(ro.Opt, boolToUchar(b))
+}
+
+// SetDeadline makes DB.Get and DB.MultiGet fail with ErrTimedOut once t has
+// passed. RocksDB checks the deadline between the steps of a read, so reads
+// may take a little longer. The zero time removes the deadline.
+func (ro *ReadOptions) SetDeadline(t time.Time) {
+	var us int64
+	if !t.IsZero() {
+		us = t.UnixMicro()
+	}
+	C.leveldb_readoptions_set_deadline(ro.Opt, C.uint64_t(us))
+}
+
+// SetIOTimeout makes reads fail with ErrTimedOut when a single read of a
+// file takes longer than d. Zero removes the timeout.
+func (ro *ReadOptions) SetIOTimeout(d time.Duration) {
+	if d < 0 {
+		d = 0
+	}
+	C.leveldb_readoptions_set_io_timeout(ro.Opt, C.uint64_t(d/time.Microsecond))
 }
 
-// Close deallocates the WriteOptions, freeing its underlying C struct.
+// Close deallocates the WriteOptions, freeing its underlying C struct. The
+// methods of the DB return ErrClosed for a closed WriteOptions. Closing it
+// again does nothing.
 func (wo *WriteOptions) Close() {
+	if wo.Opt == nil {
+		return
+	}
 	C.leveldb_writeoptions_destroy(wo.Opt)
+	wo.Opt = nil
+	untrackLeak(wo.leak)
 }
 
 // SetSync controls whether each write performed with this WriteOptions will
@@ -262,3 +509,74 @@ func (wo *WriteOptions) SetSync(b bool) {
 func (wo *WriteOptions) SetDisableWAL(b bool) {
 	C.leveldb_writeoptions_set_disable_wal(wo.Opt, boolToUchar(b))
 }
+
+// Close deallocates the TransactionDBOptions, freeing its underlying C
+// struct.
+func (o *TransactionDBOptions) Close() {
+	if o.Opt == nil {
+		return
+	}
+	C.leveldb_transactiondb_options_destroy(o.Opt)
+	o.Opt = nil
+}
+
+// SetTransactionLockTimeout sets how long a transaction waits for a lock
+// held by another transaction by default, before failing with a
+// LockTimeoutError. A negative timeout waits forever. It defaults to one
+// second.
+func (o *TransactionDBOptions) SetTransactionLockTimeout(d time.Duration) {
+	C.leveldb_transactiondb_options_set_transaction_lock_timeout(o.Opt, C.int64_t(lockTimeoutMillis(d)))
+}
+
+// SetDefaultLockTimeout sets how long the writes made outside of
+// transactions wait for a lock held by a transaction. A negative timeout
+// waits forever. It defaults to one second.
+func (o *TransactionDBOptions) SetDefaultLockTimeout(d time.Duration) {
+	C.leveldb_transactiondb_options_set_default_lock_timeout(o.Opt, C.int64_t(lockTimeoutMillis(d)))
+}
+
+// SetMaxNumDeadlocks sets how many of the most recent deadlocks are kept to
+// be reported with DeadlockError. It defaults to 5.
+func (o *TransactionDBOptions) SetMaxNumDeadlocks(n int) {
+	C.leveldb_transactiondb_options_set_max_num_deadlocks(o.Opt, C.uint32_t(n))
+}
+
+// Close deallocates the TransactionOptions, freeing its underlying C struct.
+func (o *TransactionOptions) Close() {
+	if o.Opt == nil {
+		return
+	}
+	C.leveldb_transaction_options_destroy(o.Opt)
+	o.Opt = nil
+}
+
+// SetSetSnapshot controls whether the transaction takes a snapshot when it
+// begins, which makes it conflict with the writes made to the keys it locks
+// since then, rather than since they were locked. It defaults to false.
+func (o *TransactionOptions) SetSetSnapshot(b bool) {
+	C.leveldb_transaction_options_set_set_snapshot(o.Opt, boolToUchar(b))
+}
+
+// SetDeadlockDetect controls whether the transaction checks for deadlocks
+// when it waits for a lock, failing with a DeadlockError when it finds one
+// instead of waiting until it times out. It defaults to false.
+func (o *TransactionOptions) SetDeadlockDetect(b bool) {
+	C.leveldb_transaction_options_set_deadlock_detect(o.Opt, boolToUchar(b))
+}
+
+// SetLockTimeout sets how long the transaction waits for a lock held by
+// another transaction, overriding
+// TransactionDBOptions.SetTransactionLockTimeout. A zero timeout doesn't wait
+// at all, and a negative one restores the TransactionDBOptions default.
+func (o *TransactionOptions) SetLockTimeout(d time.Duration) {
+	C.leveldb_transaction_options_set_lock_timeout(o.Opt, C.int64_t(lockTimeoutMillis(d)))
+}
+
+// lockTimeoutMillis converts a lock timeout to the milliseconds RocksDB
+// expects, where -1 means no timeout.
+func lockTimeoutMillis(d time.Duration) int64 {
+	if d < 0 {
+		return -1
+	}
+	return d.Milliseconds()
+}
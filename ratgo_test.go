@@ -1,12 +1,17 @@
 package ratgo
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path"
 	"runtime"
-
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 /*func TestMain(t *testing.T) {
@@ -164,7 +169,9 @@ func TestRago(t *testing.T) {
 	}
 
 	// Backup operation
-	db.DisableFileDeletions()
+	if err := db.DisableFileDeletions(); err != nil {
+		t.Fatalf("disable file deletions failed, err %v", err)
+	}
 	beforeFiles, beforeManifestFileSize, err := db.GetLiveFiles(true)
 	if err != nil {
 		t.Errorf("get live files failed, error %v", err.Error())
@@ -198,6 +205,1421 @@ func TestRago(t *testing.T) {
 	// Destroy
 	err = DestroyDatabase(dbName, options)
 	if err != nil {
-		t.Fatal("Destroy database error, %v\n", err)
+		t.Fatalf("Destroy database error, %v\n", err)
+	}
+}
+
+// testDBName returns a path for a test database named name in the current
+// directory, making sure that no database exists there yet.
+func testDBName(tb testing.TB, name string) string {
+	tb.Helper()
+	dbPath, err := os.Getwd()
+	if err != nil {
+		panic(fmt.Sprintf("can't get current file path %v)", err))
+	}
+	dbName := path.Join(dbPath, name)
+	options := NewOptions()
+	defer options.Close()
+	if err := DestroyDatabase(dbName, options); err != nil {
+		tb.Fatalf("Destroy db %s error, %v\n", dbName, err)
+	}
+	return dbName
+}
+
+// newTestOptions returns the path of a new test database named name, and the
+// Options that create it once opts are applied to them. The database is
+// destroyed and the Options are closed when the test ends.
+func newTestOptions(tb testing.TB, name string, opts ...func(*Options)) (string, *Options) {
+	tb.Helper()
+	dbName := testDBName(tb, name)
+	options := NewOptions()
+	options.SetCreateIfMissing(true)
+	for _, opt := range opts {
+		opt(options)
+	}
+	tb.Cleanup(options.Close)
+	tb.Cleanup(func() { DestroyDatabase(dbName, options) })
+	return dbName, options
+}
+
+// newTestReadWriteOptions returns WriteOptions and ReadOptions that are
+// closed when the test ends.
+func newTestReadWriteOptions(tb testing.TB) (*WriteOptions, *ReadOptions) {
+	wo := NewWriteOptions()
+	tb.Cleanup(wo.Close)
+	ro := NewReadOptions()
+	tb.Cleanup(ro.Close)
+	return wo, ro
+}
+
+// openTestDB opens a new test database like newTestOptions, and returns it
+// with WriteOptions and ReadOptions. They are all closed, and the database
+// destroyed, when the test ends.
+func openTestDB(tb testing.TB, name string, opts ...func(*Options)) (*DB, *WriteOptions, *ReadOptions) {
+	tb.Helper()
+	dbName, options := newTestOptions(tb, name, opts...)
+	db, err := Open(dbName, options)
+	if err != nil {
+		tb.Fatalf("can't create db:%s, err %v\n", dbName, err)
+	}
+	tb.Cleanup(func() { db.Close() })
+	wo, ro := newTestReadWriteOptions(tb)
+	return db, wo, ro
+}
+
+type reverseComparator struct{}
+
+func (reverseComparator) Compare(a, b []byte) int { return bytes.Compare(b, a) }
+func (reverseComparator) Name() string            { return "ratgo.test.reverse" }
+
+func TestComparator(t *testing.T) {
+	db, wo, ro := openTestDB(t, "testdb_comparator", func(o *Options) { o.SetComparator(reverseComparator{}) })
+
+	for _, k := range []string{"a", "c", "b"} {
+		if err := db.Put(wo, []byte(k), []byte(k)); err != nil {
+			t.Fatalf("put key:%s failed, err %v\n", k, err)
+		}
+	}
+
+	var got []string
+	iter := db.NewIterator(ro)
+	for iter.SeekToFirst(); iter.Valid(); iter.Next() {
+		got = append(got, string(iter.Key()))
+	}
+	iter.Close()
+	if fmt.Sprint(got) != "[c b a]" {
+		t.Errorf("keys should be ordered by the comparator, got %v", got)
+	}
+}
+
+// setFilter is a filter that holds its keys, separated by NULs, and counts
+// how often it is called.
+type setFilter struct {
+	created, matched atomic.Int64
+}
+
+func (f *setFilter) CreateFilter(keys [][]byte) []byte {
+	f.created.Add(1)
+	return append(bytes.Join(keys, []byte{0}), 0)
+}
+
+func (f *setFilter) KeyMayMatch(key, filter []byte) bool {
+	f.matched.Add(1)
+	for _, k := range bytes.Split(filter, []byte{0}) {
+		if bytes.Equal(k, key) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *setFilter) Name() string { return "ratgo.test.set" }
+
+func TestFilterPolicy(t *testing.T) {
+	filter := &setFilter{}
+	policy := NewFilterPolicy(filter)
+	t.Cleanup(policy.Close)
+	db, wo, ro := openTestDB(t, "testdb_filter_policy", func(o *Options) { o.SetFilterPolicy(policy) })
+
+	for _, k := range []string{"user1", "user2", "user3"} {
+		if err := db.Put(wo, []byte(k), []byte("value")); err != nil {
+			t.Fatalf("put key:%s failed, err %v\n", k, err)
+		}
+	}
+	// Write the keys to a table, which has a filter.
+	db.CompactRange(Range{})
+	if filter.created.Load() == 0 {
+		t.Fatal("writing a table should create a filter")
+	}
+
+	for k, exists := range map[string]bool{"user1": true, "user3": true, "user4": false, "user": false} {
+		data, err := db.Get(ro, []byte(k))
+		if err != nil || (data != nil) != exists {
+			t.Errorf("key:%s should exist: %v, but the result is %s (%v)", k, exists, data, err)
+		}
+	}
+	if filter.matched.Load() == 0 {
+		t.Error("reading from a table should consult its filter")
+	}
+}
+
+func TestBloomFilterWithTransform(t *testing.T) {
+	trim := func(key []byte) []byte { return bytes.TrimRight(key, " ") }
+	policy := NewBloomFilterWithTransform(10, "ratgo.test.trim", trim)
+	defer policy.Close()
+
+	filter := policy.createFilter([][]byte{[]byte("user1  "), []byte("user2")})
+	for _, k := range []string{"user1", "user1 ", "user1   ", "user2", "user2  "} {
+		if !policy.keyMayMatch([]byte(k), filter) {
+			t.Errorf("key:%q only differs from a key of the filter in trailing spaces, but doesn't match", k)
+		}
+	}
+	misses := 0
+	for i := 0; i < 100; i++ {
+		if !policy.keyMayMatch([]byte(fmt.Sprintf("other%d", i)), filter) {
+			misses++
+		}
+	}
+	if misses == 0 {
+		t.Error("the filter should reject keys that aren't in it")
+	}
+}
+
+func TestMergeOperators(t *testing.T) {
+	db, wo, ro := openTestDB(t, "testdb_merge", func(o *Options) { o.SetMergeOperator(NewUint64AddOperator()) })
+
+	counter := []byte("counter")
+	for i := 0; i < 3; i++ {
+		if err := db.Merge(wo, counter, EncodeUint64(1)); err != nil {
+			t.Fatalf("merge key:%s failed, err %v\n", counter, err)
+		}
+	}
+	wb := NewWriteBatch()
+	wb.Merge(counter, EncodeUint64(2))
+	if err := db.Write(wo, wb); err != nil {
+		t.Fatalf("write batch error, %v", err)
+	}
+	wb.Close()
+
+	data, err := db.Get(ro, counter)
+	if err != nil {
+		t.Fatalf("read key:%s failed, err %v\n", counter, err)
+	}
+	if n, err := DecodeUint64(data); err != nil || n != 5 {
+		t.Errorf("counter should be 5, but the result is %d (%v)", n, err)
+	}
+}
+
+func TestBuiltinMergeOperators(t *testing.T) {
+	tests := []struct {
+		op       MergeOperator
+		existing []byte
+		operands [][]byte
+		expect   []byte
+	}{
+		{NewStringAppendOperator([]byte(",")), nil, [][]byte{[]byte("a"), []byte("b")}, []byte("a,b")},
+		{NewStringAppendOperator([]byte(",")), []byte("a"), [][]byte{[]byte("b")}, []byte("a,b")},
+		{NewInt64MaxOperator(), EncodeInt64(-3), [][]byte{EncodeInt64(-5), EncodeInt64(2)}, EncodeInt64(2)},
+		{NewInt64MinOperator(), EncodeInt64(-3), [][]byte{EncodeInt64(-5), EncodeInt64(2)}, EncodeInt64(-5)},
+		{NewBitwiseOrOperator(), []byte{0x01}, [][]byte{{0x02, 0x10}}, []byte{0x03, 0x10}},
+	}
+	for _, test := range tests {
+		result, ok := test.op.FullMerge([]byte("key"), test.existing, test.operands)
+		if !ok || !bytes.Equal(result, test.expect) {
+			t.Errorf("%s: expect %v, but the result is %v (%v)", test.op.Name(), test.expect, result, ok)
+		}
+	}
+	if _, ok := NewUint64AddOperator().PartialMerge([]byte("key"), []byte("bad"), EncodeUint64(1)); ok {
+		t.Error("merging a malformed uint64 should fail")
+	}
+}
+
+// expiredFilter removes the keys prefixed with "expired:" and upper-cases
+// the values of the keys prefixed with "rewrite:".
+type expiredFilter struct{}
+
+func (expiredFilter) Filter(level int, key, existingValue []byte) (bool, []byte) {
+	if bytes.HasPrefix(key, []byte("expired:")) {
+		return true, nil
+	}
+	if bytes.HasPrefix(key, []byte("rewrite:")) {
+		return false, bytes.ToUpper(existingValue)
+	}
+	return false, nil
+}
+
+func (expiredFilter) Name() string { return "ratgo.test.expired" }
+
+func TestCompactionFilter(t *testing.T) {
+	db, wo, ro := openTestDB(t, "testdb_compaction_filter", func(o *Options) { o.SetCompactionFilter(expiredFilter{}) })
+
+	for _, k := range []string{"expired:1", "expired:2", "keep:1", "rewrite:1"} {
+		if err := db.Put(wo, []byte(k), []byte("value")); err != nil {
+			t.Fatalf("put key:%s failed, err %v\n", k, err)
+		}
+	}
+	db.CompactRange(Range{})
+
+	expect := map[string][]byte{
+		"expired:1": nil,
+		"expired:2": nil,
+		"keep:1":    []byte("value"),
+		"rewrite:1": []byte("VALUE"),
+	}
+	for k, v := range expect {
+		data, err := db.Get(ro, []byte(k))
+		if err != nil {
+			t.Fatalf("read key:%s failed, err %v\n", k, err)
+		}
+		if !bytes.Equal(data, v) {
+			t.Errorf("key:%s=%q after compaction, expect %q", k, data, v)
+		}
+	}
+}
+
+func TestColumnFamilies(t *testing.T) {
+	dbName, options := newTestOptions(t, "testdb_column_families")
+	db, err := Open(dbName, options)
+	if err != nil {
+		t.Fatalf("can't create db:%s, err %v\n", dbName, err)
+	}
+	users, err := db.CreateColumnFamily(options, "users")
+	if err != nil {
+		t.Fatalf("create column family failed, err %v", err)
+	}
+	users.Close()
+	db.Close()
+
+	names, err := ListColumnFamilies(dbName, options)
+	if err != nil {
+		t.Fatalf("list column families failed, err %v", err)
+	}
+	if fmt.Sprint(names) != "[default users]" {
+		t.Errorf("expect column families [default users], but the result is %v", names)
+	}
+
+	db, cfs, err := OpenColumnFamilies(dbName, options, names, []*Options{options, options})
+	if err != nil {
+		t.Fatalf("can't open db:%s with column families, err %v\n", dbName, err)
+	}
+	t.Cleanup(func() { db.Close() })
+	for _, cf := range cfs {
+		t.Cleanup(cf.Close)
+	}
+	def, users := cfs[0], cfs[1]
+	wo, ro := newTestReadWriteOptions(t)
+
+	k := []byte("user1")
+	wb := NewWriteBatch()
+	wb.PutCF(def, k, []byte("default"))
+	wb.PutCF(users, k, []byte("users"))
+	if err := db.Write(wo, wb); err != nil {
+		t.Fatalf("write batch error, %v", err)
+	}
+	wb.Close()
+
+	values, errs := db.MultiGetCF(ro, []*ColumnFamilyHandle{def, users}, [][]byte{k, k})
+	for i, expect := range []string{"default", "users"} {
+		if errs[i] != nil || string(values[i]) != expect {
+			t.Errorf("expect %s in column family %s, but the result is %s (%v)", expect, cfs[i].Name(), values[i], errs[i])
+		}
+	}
+
+	if err := db.DeleteCF(wo, users, k); err != nil {
+		t.Fatalf("delete key:%s failed, err %v", k, err)
+	}
+	if data, err := db.GetCF(ro, users, k); err != nil || data != nil {
+		t.Errorf("key:%s should be deleted from users, but the result is %s (%v)", k, data, err)
+	}
+	if data, err := db.Get(ro, k); err != nil || string(data) != "default" {
+		t.Errorf("key:%s should still be in default, but the result is %s (%v)", k, data, err)
+	}
+
+	users.Close()
+	if err := db.PutCF(wo, users, k, []byte("users")); err != ErrClosed {
+		t.Errorf("put to a closed column family should fail with ErrClosed, got %v", err)
+	}
+	wb = NewWriteBatch()
+	defer wb.Close()
+	wb.PutCF(users, k, []byte("users"))
+	if err := db.Write(wo, wb); err != ErrClosed {
+		t.Errorf("writing a batch with a closed column family should fail with ErrClosed, got %v", err)
+	}
+}
+
+func TestCheckpoint(t *testing.T) {
+	db, wo, ro := openTestDB(t, "testdb_checkpoint_source")
+	checkpointName, options := newTestOptions(t, "testdb_checkpoint")
+
+	k1, k2 := []byte("user1"), []byte("user2")
+	if err := db.Put(wo, k1, []byte("value1")); err != nil {
+		t.Fatalf("put key:%s failed, err %v\n", k1, err)
+	}
+	// A directory that happens to be named like a staging directory is left
+	// alone.
+	unrelated := checkpointName + ".tmp"
+	if err := os.MkdirAll(unrelated, 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(unrelated) })
+	if err := db.CreateCheckpoint(checkpointName); err != nil {
+		t.Fatalf("create checkpoint failed, err %v", err)
+	}
+	if _, err := os.Stat(unrelated); err != nil {
+		t.Errorf("checkpoint removed %s, err %v", unrelated, err)
+	}
+	if err := db.CreateCheckpoint(checkpointName); err == nil {
+		t.Error("checkpoint into an existing directory should fail")
+	}
+	if err := db.Put(wo, k2, []byte("value2")); err != nil {
+		t.Fatalf("put key:%s failed, err %v\n", k2, err)
+	}
+
+	checkpoint, err := Open(checkpointName, options)
+	if err != nil {
+		t.Fatalf("can't open checkpoint:%s, err %v\n", checkpointName, err)
+	}
+	t.Cleanup(func() { checkpoint.Close() })
+	if data, err := checkpoint.Get(ro, k1); err != nil || string(data) != "value1" {
+		t.Errorf("key:%s should be in the checkpoint, but the result is %s (%v)", k1, data, err)
+	}
+	if data, err := checkpoint.Get(ro, k2); err != nil || data != nil {
+		t.Errorf("key:%s was written after the checkpoint, but the result is %s (%v)", k2, data, err)
+	}
+}
+
+func TestBackupEngine(t *testing.T) {
+	db, wo, ro := openTestDB(t, "testdb_backup_source")
+	backupName := testDBName(t, "testdb_backup")
+	restoreName, options := newTestOptions(t, "testdb_backup_restore")
+
+	be, err := OpenBackupEngine(backupName)
+	if err != nil {
+		t.Fatalf("can't open backup engine:%s, err %v\n", backupName, err)
+	}
+	t.Cleanup(func() { os.RemoveAll(backupName) })
+	t.Cleanup(func() { be.Close() })
+
+	k1, k2, k3 := []byte("user1"), []byte("user2"), []byte("user3")
+	if err := db.Put(wo, k1, []byte("value1")); err != nil {
+		t.Fatalf("put key:%s failed, err %v\n", k1, err)
+	}
+	if err := be.CreateNewBackup(db, true); err != nil {
+		t.Fatalf("create backup failed, err %v", err)
+	}
+	if err := db.Put(wo, k2, []byte("value2")); err != nil {
+		t.Fatalf("put key:%s failed, err %v\n", k2, err)
+	}
+	// The second backup relies on the write-ahead log for k2.
+	if err := be.CreateNewBackup(db, false); err != nil {
+		t.Fatalf("create backup failed, err %v", err)
+	}
+	if err := db.Put(wo, k3, []byte("value3")); err != nil {
+		t.Fatalf("put key:%s failed, err %v\n", k3, err)
+	}
+	if err := be.CreateNewBackup(db, true); err != nil {
+		t.Fatalf("create backup failed, err %v", err)
+	}
+
+	infos, err := be.GetBackupInfo()
+	if err != nil || len(infos) != 3 {
+		t.Fatalf("expected 3 backups, got %v (%v)", infos, err)
+	}
+	for i, info := range infos {
+		if info.ID != uint32(i+1) || info.NumFiles == 0 || info.Size == 0 || info.Timestamp == 0 {
+			t.Errorf("unexpected backup info %+v", info)
+		}
+		if err := be.VerifyBackup(info.ID); err != nil {
+			t.Errorf("verify backup %d failed, err %v", info.ID, err)
+		}
+	}
+
+	if err := be.DeleteBackup(3); err != nil {
+		t.Fatalf("delete backup failed, err %v", err)
+	}
+	if err := be.PurgeOldBackups(1); err != nil {
+		t.Fatalf("purge old backups failed, err %v", err)
+	}
+	if infos, err := be.GetBackupInfo(); err != nil || len(infos) != 1 || infos[0].ID != 2 {
+		t.Fatalf("only backup 2 should be left, got %v (%v)", infos, err)
+	}
+	if err := be.VerifyBackup(1); err == nil {
+		t.Error("verifying a purged backup should fail")
+	}
+	if err := be.VerifyBackup(2); err != nil {
+		t.Errorf("verify backup 2 failed, err %v", err)
+	}
+
+	if err := be.RestoreDBFromLatestBackup(restoreName); err != nil {
+		t.Fatalf("restore failed, err %v", err)
+	}
+	if err := be.RestoreDBFromLatestBackup(restoreName); err == nil {
+		t.Error("restoring into a non-empty directory should fail")
+	}
+
+	restored, err := Open(restoreName, options)
+	if err != nil {
+		t.Fatalf("can't open restored db:%s, err %v\n", restoreName, err)
+	}
+	t.Cleanup(func() { restored.Close() })
+	for _, k := range [][]byte{k1, k2} {
+		if data, err := restored.Get(ro, k); err != nil || data == nil {
+			t.Errorf("key:%s should be in the restored db, but the result is %s (%v)", k, data, err)
+		}
+	}
+	if data, err := restored.Get(ro, k3); err != nil || data != nil {
+		t.Errorf("key:%s was written after the backup, but the result is %s (%v)", k3, data, err)
+	}
+
+	be.Close()
+	if _, err := be.GetBackupInfo(); err != ErrClosed {
+		t.Errorf("a closed backup engine should fail with ErrClosed, got %v", err)
+	}
+	if err := be.CreateNewBackup(db, true); err != ErrClosed {
+		t.Errorf("backing up with a closed backup engine should fail with ErrClosed, got %v", err)
+	}
+}
+
+func TestOpenForReadOnly(t *testing.T) {
+	dbName, options := newTestOptions(t, "testdb_read_only")
+	db, err := Open(dbName, options)
+	if err != nil {
+		t.Fatalf("can't create db:%s, err %v\n", dbName, err)
+	}
+	t.Cleanup(func() { db.Close() })
+	wo, ro := newTestReadWriteOptions(t)
+
+	k := []byte("user1")
+	if err := db.Put(wo, k, []byte("value1")); err != nil {
+		t.Fatalf("put key:%s failed, err %v\n", k, err)
+	}
+	if _, err := OpenForReadOnly(dbName, options, true); err == nil {
+		t.Error("the write-ahead log isn't empty, opening should fail")
+	}
+	// Reopening flushes the write-ahead log.
+	db.Close()
+	if db, err = Open(dbName, options); err != nil {
+		t.Fatalf("can't reopen db:%s, err %v\n", dbName, err)
+	}
+
+	// The primary still holds the LOCK.
+	readOnly, err := OpenForReadOnly(dbName, options, true)
+	if err != nil {
+		t.Fatalf("can't open db:%s read-only, err %v\n", dbName, err)
+	}
+	t.Cleanup(func() { readOnly.Close() })
+	if data, err := readOnly.Get(ro, k); err != nil || string(data) != "value1" {
+		t.Errorf("key:%s should be in the db, but the result is %s (%v)", k, data, err)
+	}
+
+	wb := NewWriteBatch()
+	defer wb.Close()
+	errs := map[string]error{
+		"Put":          readOnly.Put(wo, k, []byte("value2")),
+		"Delete":       readOnly.Delete(wo, k),
+		"Merge":        readOnly.Merge(wo, k, []byte("value2")),
+		"Write":        readOnly.Write(wo, wb),
+		"Flush":        readOnly.Flush(&FlushOptions{}),
+		"CompactRange": readOnly.CompactRange(Range{}),
+	}
+	for method, err := range errs {
+		if _, ok := err.(ReadOnlyError); !ok {
+			t.Errorf("%s on a read-only db should return a ReadOnlyError, got %v", method, err)
+		}
+	}
+	// A read-only db can't keep its files while they are copied.
+	if err := readOnly.CreateCheckpoint(path.Join(t.TempDir(), "checkpoint")); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("a checkpoint of a read-only db should fail with ErrNotSupported, got %v", err)
+	}
+}
+
+func TestOpenAsSecondary(t *testing.T) {
+	dbName, options := newTestOptions(t, "testdb_primary")
+	secondaryName := testDBName(t, "testdb_secondary")
+	db, err := Open(dbName, options)
+	if err != nil {
+		t.Fatalf("can't create db:%s, err %v\n", dbName, err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	secondary, err := OpenAsSecondary(dbName, secondaryName, options)
+	if err != nil {
+		t.Fatalf("can't open secondary:%s, err %v\n", secondaryName, err)
+	}
+	t.Cleanup(func() { os.RemoveAll(secondaryName) })
+	t.Cleanup(func() { secondary.Close() })
+	wo, ro := newTestReadWriteOptions(t)
+
+	k := []byte("user1")
+	if err := db.Put(wo, k, []byte("value1")); err != nil {
+		t.Fatalf("put key:%s failed, err %v\n", k, err)
+	}
+	if data, err := secondary.Get(ro, k); err != nil || data != nil {
+		t.Errorf("key:%s shouldn't be visible before catching up, but the result is %s (%v)", k, data, err)
+	}
+	if err := secondary.TryCatchUpWithPrimary(); err != nil {
+		t.Fatalf("catch up failed, err %v", err)
+	}
+	if data, err := secondary.Get(ro, k); err != nil || string(data) != "value1" {
+		t.Errorf("key:%s should be visible after catching up, but the result is %s (%v)", k, data, err)
+	}
+	if err := secondary.Put(wo, k, []byte("value2")); err == nil {
+		t.Error("put on a secondary instance should fail")
+	}
+}
+
+func TestOpenWithTTL(t *testing.T) {
+	dbName, options := newTestOptions(t, "testdb_ttl")
+	db, err := OpenWithTTL(dbName, options, time.Second)
+	if err != nil {
+		t.Fatalf("can't create db:%s, err %v\n", dbName, err)
+	}
+	t.Cleanup(func() { db.Close() })
+	wo, ro := newTestReadWriteOptions(t)
+
+	k1, k2 := []byte("session1"), []byte("session2")
+	if err := db.Put(wo, k1, []byte("value1")); err != nil {
+		t.Fatalf("put key:%s failed, err %v\n", k1, err)
+	}
+	if err := db.PutWithTTL(wo, k2, []byte("value2"), time.Hour); err != nil {
+		t.Fatalf("put key:%s failed, err %v\n", k2, err)
+	}
+	if data, err := db.Get(ro, k1); err != nil || string(data) != "value1" {
+		t.Errorf("key:%s should be in the db, but the result is %s (%v)", k1, data, err)
+	}
+
+	time.Sleep(2100 * time.Millisecond)
+	db.CompactRange(Range{})
+	if data, err := db.Get(ro, k1); err != nil || data != nil {
+		t.Errorf("key:%s should have expired, but the result is %s (%v)", k1, data, err)
+	}
+	if data, err := db.Get(ro, k2); err != nil || string(data) != "value2" {
+		t.Errorf("key:%s has a longer ttl, but the result is %s (%v)", k2, data, err)
+	}
+}
+
+func TestOptimisticTransaction(t *testing.T) {
+	dbName, options := newTestOptions(t, "testdb_optimistic_transaction")
+	db, err := OpenOptimisticTransactionDB(dbName, options)
+	if err != nil {
+		t.Fatalf("can't create db:%s, err %v\n", dbName, err)
+	}
+	t.Cleanup(func() { db.Close() })
+	wo, ro := newTestReadWriteOptions(t)
+
+	k1, k2 := []byte("balance1"), []byte("balance2")
+	if err := db.Put(wo, k1, []byte("100")); err != nil {
+		t.Fatalf("put key:%s failed, err %v\n", k1, err)
+	}
+
+	txn := db.BeginTransaction(wo)
+	defer txn.Close()
+	if data, err := txn.GetForUpdate(ro, k1); err != nil || string(data) != "100" {
+		t.Fatalf("key:%s should be in the db, but the result is %s (%v)", k1, data, err)
+	}
+	if err := txn.Put(k2, []byte("50")); err != nil {
+		t.Fatalf("put key:%s failed, err %v\n", k2, err)
+	}
+	if data, err := txn.Get(ro, k2); err != nil || string(data) != "50" {
+		t.Errorf("the transaction should see its own write of key:%s, but the result is %s (%v)", k2, data, err)
+	}
+	if data, err := db.Get(ro, k2); err != nil || data != nil {
+		t.Errorf("key:%s shouldn't be visible before commit, but the result is %s (%v)", k2, data, err)
+	}
+
+	// A write outside the transaction to a key it read for update.
+	if err := db.Put(wo, k1, []byte("90")); err != nil {
+		t.Fatalf("put key:%s failed, err %v\n", k1, err)
+	}
+	if err := txn.Commit(); err == nil {
+		t.Fatal("commit should fail with a conflict")
+	} else if !errors.As(err, new(*ConflictError)) || !errors.As(err, new(*Error)) {
+		t.Fatalf("commit should fail with a ConflictError, got %v", err)
+	}
+	if data, err := db.Get(ro, k2); err != nil || data != nil {
+		t.Errorf("key:%s shouldn't be written by a failed commit, but the result is %s (%v)", k2, data, err)
+	}
+
+	retry := db.BeginTransaction(wo)
+	defer retry.Close()
+	if _, err := retry.GetForUpdate(ro, k1); err != nil {
+		t.Fatalf("get key:%s failed, err %v", k1, err)
+	}
+	if err := retry.Put(k2, []byte("50")); err != nil {
+		t.Fatalf("put key:%s failed, err %v\n", k2, err)
+	}
+	if err := retry.Commit(); err != nil {
+		t.Fatalf("commit failed, err %v", err)
+	}
+	if data, err := db.Get(ro, k2); err != nil || string(data) != "50" {
+		t.Errorf("key:%s should be committed, but the result is %s (%v)", k2, data, err)
+	}
+
+	// An iterator of a transaction is a handle of the DB like any other.
+	it := retry.NewIterator(ro)
+	if handles := db.OpenHandles(); len(handles) != 3 {
+		t.Errorf("expected the 2 transactions and the iterator to be open, got %v", handles)
+	}
+	// Closing the transaction releases its iterator.
+	retry.Close()
+	if it.SeekToFirst(); it.Valid() || it.GetError() != ErrClosed {
+		t.Errorf("the iterator of a closed transaction should be invalid, but GetError returns %v", it.GetError())
+	}
+	it.Close()
+	if err := retry.Put(k2, []byte("60")); err != ErrClosed {
+		t.Errorf("put to a closed transaction should fail with ErrClosed, got %v", err)
+	}
+}
+
+func TestTransactionDB(t *testing.T) {
+	dbName, options := newTestOptions(t, "testdb_transaction", func(o *Options) { o.SetAllow2PC(true) })
+	txnDBOpts := NewTransactionDBOptions()
+	txnDBOpts.SetTransactionLockTimeout(5 * time.Second)
+	defer txnDBOpts.Close()
+
+	db, err := OpenTransactionDB(dbName, options, txnDBOpts)
+	if err != nil {
+		t.Fatalf("can't create db:%s, err %v\n", dbName, err)
+	}
+	t.Cleanup(func() { db.Close() })
+	wo, ro := newTestReadWriteOptions(t)
+	to := NewTransactionOptions()
+	to.SetDeadlockDetect(true)
+	defer to.Close()
+	noWait := NewTransactionOptions()
+	noWait.SetLockTimeout(0)
+	defer noWait.Close()
+
+	k1, k2 := []byte("account1"), []byte("account2")
+
+	// Lock timeouts.
+	txn1 := db.BeginTransaction(wo, to)
+	if err := txn1.Put(k1, []byte("100")); err != nil {
+		t.Fatalf("put key:%s failed, err %v\n", k1, err)
+	}
+	txn2 := db.BeginTransaction(wo, noWait)
+	if _, err := txn2.GetForUpdate(ro, k1); err == nil {
+		t.Error("locking a locked key should time out")
+	} else if e := (*Error)(nil); !errors.As(err, &e) || e.SubCode != SubCodeLockTimeout || !errors.As(err, new(*LockTimeoutError)) {
+		t.Errorf("locking a locked key should fail with a LockTimeoutError, got %v", err)
+	}
+	txn2.Rollback()
+	txn2.Close()
+
+	// Deadlock detection.
+	txn2 = db.BeginTransaction(wo, to)
+	if err := txn2.Put(k2, []byte("200")); err != nil {
+		t.Fatalf("put key:%s failed, err %v\n", k2, err)
+	}
+	waiting := make(chan error)
+	go func() {
+		_, err := txn1.GetForUpdate(ro, k2)
+		waiting <- err
+	}()
+	time.Sleep(200 * time.Millisecond)
+	_, err = txn2.GetForUpdate(ro, k1)
+	if deadlock, ok := err.(*DeadlockError); !ok {
+		t.Errorf("the transactions should deadlock, got %v", err)
+	} else if len(deadlock.Cycle) != 2 {
+		t.Errorf("the deadlock should involve both transactions, got %v", deadlock)
+	} else {
+		ids := map[uint64]bool{deadlock.Cycle[0].TransactionID: true, deadlock.Cycle[1].TransactionID: true}
+		if !ids[txn1.ID()] || !ids[txn2.ID()] {
+			t.Errorf("the deadlock should involve both transactions, got %v", deadlock)
+		}
+	}
+	txn2.Rollback()
+	txn2.Close()
+	if err := <-waiting; err != nil {
+		t.Errorf("txn1 should get the lock once txn2 rolled back, err %v", err)
+	}
+
+	// Save points.
+	txn1.SetSavePoint()
+	if err := txn1.Put(k2, []byte("150")); err != nil {
+		t.Fatalf("put key:%s failed, err %v\n", k2, err)
+	}
+	if err := txn1.RollbackToSavePoint(); err != nil {
+		t.Fatalf("rollback to save point failed, err %v", err)
+	}
+	if data, err := txn1.Get(ro, k2); err != nil || data != nil {
+		t.Errorf("the write of key:%s should be rolled back, but the result is %s (%v)", k2, data, err)
+	}
+	if err := txn1.RollbackToSavePoint(); err == nil {
+		t.Error("rolling back without a save point should fail")
+	}
+
+	// Two-phase commit, recovered after a restart.
+	if err := txn1.SetName("txn1"); err != nil {
+		t.Fatalf("set name failed, err %v", err)
+	}
+	if err := txn1.Prepare(); err != nil {
+		t.Fatalf("prepare failed, err %v", err)
+	}
+	txn1.Close()
+	db.Close()
+
+	db, err = OpenTransactionDB(dbName, options, txnDBOpts)
+	if err != nil {
+		t.Fatalf("can't reopen db:%s, err %v\n", dbName, err)
+	}
+	prepared := db.GetPreparedTransactions()
+	if len(prepared) != 1 || prepared[0].Name() != "txn1" {
+		t.Fatalf("txn1 should be recovered, got %d transactions", len(prepared))
+	}
+	if data, err := db.Get(ro, k1); err != nil || data != nil {
+		t.Errorf("key:%s shouldn't be visible before commit, but the result is %s (%v)", k1, data, err)
+	}
+	if err := prepared[0].Commit(); err != nil {
+		t.Fatalf("commit failed, err %v", err)
+	}
+	prepared[0].Close()
+	if data, err := db.Get(ro, k1); err != nil || string(data) != "100" {
+		t.Errorf("key:%s should be committed, but the result is %s (%v)", k1, data, err)
+	}
+}
+
+// colonPrefix extracts the part of the keys up to and including the first
+// colon as their prefix.
+type colonPrefix struct{}
+
+func (colonPrefix) Transform(key []byte) []byte {
+	return key[:bytes.IndexByte(key, ':')+1]
+}
+
+func (colonPrefix) InDomain(key []byte) bool {
+	return bytes.IndexByte(key, ':') >= 0
+}
+
+func (colonPrefix) InRange(prefix []byte) bool {
+	return len(prefix) > 0 && bytes.IndexByte(prefix, ':') == len(prefix)-1
+}
+
+func (colonPrefix) Name() string {
+	return "ratgo.test.ColonPrefix"
+}
+
+func TestPrefixExtractor(t *testing.T) {
+	keys := []string{"user:1", "user:2", "users:1", "userx", "video:1"}
+	scan := func(db *DB, ro *ReadOptions, seek string) []string {
+		it := db.NewIterator(ro)
+		defer it.Close()
+		var found []string
+		for it.Seek([]byte(seek)); it.Valid(); it.Next() {
+			found = append(found, string(it.Key()))
+		}
+		return found
+	}
+
+	for _, st := range []SliceTransform{NewFixedPrefixTransform(5), NewCappedPrefixTransform(5), colonPrefix{}} {
+		t.Run(st.Name(), func(t *testing.T) {
+			filter := NewBloomFilter(10)
+			t.Cleanup(filter.Close)
+			db, wo, ro := openTestDB(t, "testdb_prefix_extractor", func(o *Options) {
+				o.SetPrefixExtractor(st)
+				o.SetMemtablePrefixBloomSizeRatio(0.1)
+				o.SetFilterPolicy(filter)
+			})
+			for _, k := range keys {
+				if err := db.Put(wo, []byte(k), []byte("value")); err != nil {
+					t.Fatalf("put key:%s failed, err %v\n", k, err)
+				}
+			}
+
+			ro.SetPrefixSameAsStart(true)
+			if found := scan(db, ro, "user:"); fmt.Sprint(found) != "[user:1 user:2]" {
+				t.Errorf("the scan should stop at the end of the prefix, got %v", found)
+			}
+		})
+	}
+
+	db, wo, ro := openTestDB(t, "testdb_read_prefix")
+	for _, k := range keys {
+		if err := db.Put(wo, []byte(k), []byte("value")); err != nil {
+			t.Fatalf("put key:%s failed, err %v\n", k, err)
+		}
+	}
+
+	ro.SetReadPrefix([]byte("user"))
+	if found := scan(db, ro, ""); fmt.Sprint(found) != "[user:1 user:2 users:1 userx]" {
+		t.Errorf("the scan should only return the keys with the prefix, got %v", found)
+	}
+}
+
+func TestIterateBounds(t *testing.T) {
+	db, wo, ro := openTestDB(t, "testdb_iterate_bounds")
+
+	for _, k := range []string{"a", "b", "c", "d", "e"} {
+		if err := db.Put(wo, []byte(k), []byte("value")); err != nil {
+			t.Fatalf("put key:%s failed, err %v\n", k, err)
+		}
+	}
+
+	lower, upper := []byte("b"), []byte("d")
+	ro.SetIterateLowerBound(lower)
+	ro.SetIterateUpperBound(upper)
+	// The bounds are copied.
+	lower[0], upper[0] = 'a', 'z'
+
+	it := db.NewIterator(ro)
+	var forward, backward []string
+	for it.SeekToFirst(); it.Valid(); it.Next() {
+		forward = append(forward, string(it.Key()))
+	}
+	for it.SeekToLast(); it.Valid(); it.Prev() {
+		backward = append(backward, string(it.Key()))
+	}
+	it.Close()
+	if fmt.Sprint(forward) != "[b c]" || fmt.Sprint(backward) != "[c b]" {
+		t.Errorf("the iterator should stay within [b, d), got %v and %v", forward, backward)
+	}
+
+	ro.SetIterateLowerBound(nil)
+	ro.SetIterateUpperBound(nil)
+	it2 := db.NewIterator(ro)
+	defer it2.Close()
+	n := 0
+	for it2.SeekToFirst(); it2.Valid(); it2.Next() {
+		n++
+	}
+	if n != 5 {
+		t.Errorf("without bounds, the iterator should see all 5 keys, got %d", n)
+	}
+}
+
+func TestSeekForPrev(t *testing.T) {
+	db, wo, ro := openTestDB(t, "testdb_seek_for_prev")
+	// Versions of a record, keyed by time.
+	for _, k := range []string{"t0100", "t0200", "t0300"} {
+		if err := db.Put(wo, []byte(k), []byte("value")); err != nil {
+			t.Fatalf("put key:%s failed, err %v\n", k, err)
+		}
+	}
+
+	it := db.NewIterator(ro)
+	defer it.Close()
+	for target, want := range map[string]string{"t0200": "t0200", "t0250": "t0200", "t9999": "t0300"} {
+		it.SeekForPrev([]byte(target))
+		if !it.Valid() || string(it.Key()) != want {
+			t.Errorf("SeekForPrev(%s) should land on %s", target, want)
+		}
+	}
+	it.SeekForPrev([]byte("t0000"))
+	if it.Valid() {
+		t.Errorf("SeekForPrev before the first key should be invalid, got %s", it.Key())
+	}
+}
+
+func TestReverseScan(t *testing.T) {
+	db, wo, ro := openTestDB(t, "testdb_reverse_scan")
+	for _, k := range []string{"a", "b1", "b2", "c"} {
+		if err := db.Put(wo, []byte(k), []byte("v"+k)); err != nil {
+			t.Fatalf("put key:%s failed, err %v\n", k, err)
+		}
+	}
+
+	var keys []string
+	err := db.ReverseScan(ro, []byte("b"), []byte("c"), func(key, value []byte) error {
+		keys = append(keys, string(key))
+		return nil
+	})
+	if err != nil || fmt.Sprint(keys) != "[b2 b1]" {
+		t.Errorf("ReverseScan returned %v (%v)", keys, err)
+	}
+
+	stop := errors.New("stop")
+	keys = nil
+	err = db.ReverseScan(ro, nil, nil, func(key, value []byte) error {
+		keys = append(keys, string(key))
+		return stop
+	})
+	if err != stop || fmt.Sprint(keys) != "[c]" {
+		t.Errorf("ReverseScan should stop at the error of fn, got %v (%v)", keys, err)
+	}
+}
+
+func TestSlice(t *testing.T) {
+	db, wo, ro := openTestDB(t, "testdb_slice")
+
+	k, v := []byte("user1"), bytes.Repeat([]byte("value"), 1000)
+	if err := db.Put(wo, k, v); err != nil {
+		t.Fatalf("put key:%s failed, err %v\n", k, err)
+	}
+
+	s, err := db.GetSlice(ro, k)
+	if err != nil || !s.Exists() || !bytes.Equal(s.Data(), v) {
+		t.Errorf("key:%s should be in the db, but the result is %d bytes (%v)", k, len(s.Data()), err)
+	}
+	s.Free()
+	if s.Data() != nil && len(s.Data()) != 0 {
+		t.Error("a freed Slice should be empty")
+	}
+
+	missing, err := db.GetSlice(ro, []byte("user2"))
+	if err != nil || missing.Exists() || missing.Data() != nil {
+		t.Errorf("key:user2 shouldn't be in the db, but the result is %v (%v)", missing, err)
+	}
+	missing.Free()
+
+	it := db.NewIterator(ro)
+	defer it.Close()
+	it.SeekToFirst()
+	if !it.Valid() {
+		t.Fatal("the iterator should be valid")
+	}
+	key, value := it.KeySlice(), it.ValueSlice()
+	if !bytes.Equal(key.Data(), k) || !bytes.Equal(value.Data(), v) {
+		t.Errorf("the iterator slices should hold key:%s and its value", k)
+	}
+	key.Free()
+	value.Free()
+}
+
+func TestIteratorNextBatch(t *testing.T) {
+	db, wo, ro := openTestDB(t, "testdb_nextbatch")
+
+	for i := 0; i < 10; i++ {
+		k := []byte(fmt.Sprintf("key%d", i))
+		if err := db.Put(wo, k, bytes.Repeat(k, i)); err != nil {
+			t.Fatalf("put key:%s failed, err %v\n", k, err)
+		}
+	}
+	// The value of key9 alone is larger than a batch.
+	if err := db.Put(wo, []byte("key9"), bytes.Repeat([]byte("v"), 100)); err != nil {
+		t.Fatalf("put key:key9 failed, err %v\n", err)
+	}
+
+	it := db.NewIterator(ro)
+	defer it.Close()
+
+	var sizes []int
+	var got []string
+	it.SeekToFirst()
+	for {
+		keys, values := it.NextBatch(4, 50)
+		if len(keys) == 0 {
+			break
+		}
+		sizes = append(sizes, len(keys))
+		for i := range keys {
+			got = append(got, string(keys[i]))
+		}
+		if string(keys[0]) == "key9" && len(values[0]) != 100 {
+			t.Errorf("the value of key9 should have 100 bytes, but has %d", len(values[0]))
+		}
+	}
+	if err := it.GetError(); err != nil {
+		t.Fatalf("iterate failed, err %v\n", err)
+	}
+	if len(got) != 10 || got[0] != "key0" || got[9] != "key9" {
+		t.Errorf("the batches should hold key0 to key9, but hold %v", got)
+	}
+	// The entries of key0 to key8 have 4+4*i bytes, and key9 has 104.
+	expected := []int{4, 2, 1, 1, 1, 1}
+	if fmt.Sprint(sizes) != fmt.Sprint(expected) {
+		t.Errorf("the batch sizes should be %v, but are %v", expected, sizes)
+	}
+}
+
+func benchmarkDB(b *testing.B, name string, n int) (*DB, *ReadOptions) {
+	db, wo, ro := openTestDB(b, name)
+	for i := 0; i < n; i++ {
+		k := []byte(fmt.Sprintf("key%08d", i))
+		if err := db.Put(wo, k, bytes.Repeat(k, 4)); err != nil {
+			b.Fatalf("put key:%s failed, err %v\n", k, err)
+		}
+	}
+	return db, ro
+}
+
+func BenchmarkIteratorNext(b *testing.B) {
+	db, ro := benchmarkDB(b, "benchdb_next", 10000)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		it := db.NewIterator(ro)
+		for it.SeekToFirst(); it.Valid(); it.Next() {
+			_, _ = it.Key(), it.Value()
+		}
+		it.Close()
+	}
+}
+
+func BenchmarkIteratorNextBatch(b *testing.B) {
+	db, ro := benchmarkDB(b, "benchdb_nextbatch", 10000)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		it := db.NewIterator(ro)
+		it.SeekToFirst()
+		for keys, _ := it.NextBatch(256, 64<<10); len(keys) != 0; keys, _ = it.NextBatch(256, 64<<10) {
+		}
+		it.Close()
+	}
+}
+
+func TestErrorCodes(t *testing.T) {
+	dbName, options := newTestOptions(t, "testdb_errors", func(o *Options) { o.SetCreateIfMissing(false) })
+
+	// Without SetCreateIfMissing, opening a missing database fails.
+	db, err := Open(dbName, options)
+	if err == nil {
+		db.Close()
+		t.Fatalf("open db:%s should fail", dbName)
+	}
+	var e *Error
+	if !errors.As(err, &e) || e.Code != CodeInvalidArgument {
+		t.Errorf("open db:%s should fail with an invalid argument, got %#v", dbName, err)
+	}
+	if !errors.Is(err, ErrInvalidArgument) || errors.Is(err, ErrIOError) {
+		t.Errorf("err %v should only match ErrInvalidArgument", err)
+	}
+
+	noSpace := &Error{Code: CodeIOError, SubCode: SubCodeNoSpace, Msg: "IO error: No space left on device"}
+	if !errors.Is(noSpace, ErrIOError) || !errors.Is(noSpace, ErrNoSpace) || errors.Is(noSpace, ErrPathNotFound) {
+		t.Errorf("err %v should match ErrIOError and ErrNoSpace only", noSpace)
+	}
+}
+
+func TestClosed(t *testing.T) {
+	db, wo, ro := openTestDB(t, "testdb_closed")
+
+	k := []byte("key1")
+	if err := db.Put(wo, k, []byte("value1")); err != nil {
+		t.Fatalf("put key:%s failed, err %v\n", k, err)
+	}
+
+	it := db.NewIterator(ro)
+	it.SeekToFirst()
+	it.Close()
+	it.Close()
+	it.SeekToFirst()
+	if it.Valid() || it.Key() != nil || it.GetError() != ErrClosed {
+		t.Errorf("a closed iterator should be invalid, but GetError returns %v", it.GetError())
+	}
+
+	wb := NewWriteBatch()
+	wb.Close()
+	wb.Put(k, []byte("value2"))
+	wb.Close()
+	if err := db.Write(wo, wb); err != ErrClosed {
+		t.Errorf("writing a closed batch should fail with ErrClosed, got %v", err)
+	}
+
+	snapRO := NewReadOptions()
+	snap := db.NewSnapshot()
+	snapRO.SetSnapshot(snap)
+	db.ReleaseSnapshot(snap)
+	db.ReleaseSnapshot(snap)
+	if _, err := db.Get(snapRO, k); err != ErrClosed {
+		t.Errorf("reading with a released snapshot should fail with ErrClosed, got %v", err)
+	}
+	snapRO.Close()
+	snapRO.Close()
+	if _, err := db.Get(snapRO, k); err != ErrClosed {
+		t.Errorf("reading with closed ReadOptions should fail with ErrClosed, got %v", err)
+	}
+
+	// Close reports the handles that are still open, and releases them.
+	leaked := db.NewIterator(ro)
+	leaked.SeekToFirst()
+	var openErr *OpenHandlesError
+	if err := db.Close(); !errors.As(err, &openErr) || len(openErr.Handles) != 1 || openErr.Handles[0].Kind != "Iterator" {
+		t.Errorf("close should report the open iterator with an OpenHandlesError, got %v", err)
+	}
+	if s := leaked.KeySlice(); len(s.Data()) != 0 || leaked.GetError() != ErrClosed {
+		t.Errorf("an iterator released by Close should be invalid, but KeySlice returns %q", s.Data())
+	}
+	leaked.Close()
+	if err := db.Close(); err != nil {
+		t.Errorf("closing a closed db should do nothing, got %v", err)
+	}
+	if _, err := db.Get(ro, k); err != ErrClosed {
+		t.Errorf("get from a closed db should fail with ErrClosed, got %v", err)
+	}
+	if err := db.Put(wo, k, []byte("value2")); err != ErrClosed {
+		t.Errorf("put to a closed db should fail with ErrClosed, got %v", err)
+	}
+	if err := db.NewIterator(ro).GetError(); err != ErrClosed {
+		t.Errorf("an iterator of a closed db should fail with ErrClosed, got %v", err)
+	}
+}
+
+func TestLeakDetection(t *testing.T) {
+	SetLeakDetection(true)
+	defer SetLeakDetection(false)
+	Leaks()
+
+	closed := NewReadOptions()
+	closed.Close()
+	open := NewWriteOptions()
+	defer open.Close()
+	func() {
+		NewOptions()
+	}()
+
+	var leaks []Leak
+	for i := 0; i < 10; i++ {
+		runtime.GC()
+		time.Sleep(10 * time.Millisecond)
+		leaks = Leaks()
+		if len(leaks) != 0 && leaks[0].Collected {
+			break
+		}
+	}
+
+	var kinds []string
+	for _, l := range leaks {
+		if l.Kind == "Options" && l.Collected || l.Kind == "WriteOptions" && !l.Collected {
+			kinds = append(kinds, l.Kind)
+		}
+		if !strings.Contains(l.Stack, "TestLeakDetection") {
+			t.Errorf("the leak should have been created by the test, but was created at:\n%s", l.Stack)
+		}
+	}
+	if len(kinds) != 2 || len(leaks) != 2 {
+		t.Errorf("an unreachable Options and an open WriteOptions should leak, but the leaks are %v", leaks)
+	}
+}
+
+func TestLeakDetectionIterator(t *testing.T) {
+	db, _, ro := openTestDB(t, "testdb_leak_iterator")
+	SetLeakDetection(true)
+	defer SetLeakDetection(false)
+	Leaks()
+
+	func() {
+		db.NewIterator(ro)
+	}()
+
+	// The DB releases the iterator when it is closed, but mustn't keep it
+	// reachable until then.
+	var leaks []Leak
+	for i := 0; i < 10 && len(leaks) == 0; i++ {
+		runtime.GC()
+		time.Sleep(10 * time.Millisecond)
+		leaks = Leaks()
+	}
+	if len(leaks) != 1 || leaks[0].Kind != "Iterator" || !leaks[0].Collected {
+		t.Errorf("an unreachable Iterator should leak, but the leaks are %v", leaks)
+	}
+}
+
+func TestCloseWhileReading(t *testing.T) {
+	dbName, options := newTestOptions(t, "testdb_close_while_reading")
+	wo, ro := newTestReadWriteOptions(t)
+	db, err := Open(dbName, options)
+	if err != nil {
+		t.Fatalf("can't create db:%s, err %v\n", dbName, err)
+	}
+	k := []byte("key1")
+	if err := db.Put(wo, k, []byte("value1")); err != nil {
+		t.Fatalf("put key:%s failed, err %v\n", k, err)
+	}
+
+	errs := make(chan error, 4)
+	for i := 0; i < cap(errs); i++ {
+		go func() {
+			for {
+				if _, err := db.Get(ro, k); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	db.Close()
+	for i := 0; i < cap(errs); i++ {
+		if err := <-errs; err != ErrClosed {
+			t.Errorf("reads should fail with ErrClosed once the db is closed, got %v", err)
+		}
+	}
+}
+
+func TestCloseContext(t *testing.T) {
+	dbName, options := newTestOptions(t, "testdb_close_context")
+	_, ro := newTestReadWriteOptions(t)
+
+	db, err := Open(dbName, options)
+	if err != nil {
+		t.Fatalf("can't create db:%s, err %v\n", dbName, err)
+	}
+	it := db.NewIterator(ro)
+	snap := db.NewSnapshot()
+	handles := db.OpenHandles()
+	if len(handles) != 2 || !strings.Contains(handles[0].CreatedAt, "ratgo_test.go") {
+		t.Errorf("the iterator and the snapshot should be open, but the open handles are %v", handles)
+	}
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		it.Close()
+		db.ReleaseSnapshot(snap)
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := db.CloseContext(ctx); err != nil {
+		t.Errorf("close should wait for the iterator and the snapshot, but failed, err %v\n", err)
+	}
+
+	db, err = Open(dbName, options)
+	if err != nil {
+		t.Fatalf("can't open db:%s, err %v\n", dbName, err)
+	}
+	it = db.NewIterator(ro)
+	defer it.Close()
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err = db.CloseContext(ctx)
+	var openErr *OpenHandlesError
+	if !errors.As(err, &openErr) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("close should time out with an OpenHandlesError, got %v", err)
+	}
+	if len(openErr.Handles) != 1 || openErr.Handles[0].Kind != "Iterator" {
+		t.Errorf("the iterator should be reported as open, but the open handles are %v", openErr.Handles)
+	}
+	if it.Valid() || it.GetError() != ErrClosed {
+		t.Error("the iterator should be closed by the db")
+	}
+}
+
+func TestContext(t *testing.T) {
+	db, wo, ro := openTestDB(t, "testdb_context")
+	ro.SetIterateUpperBound([]byte("key500"))
+
+	keys := make([][]byte, 600)
+	wb := NewWriteBatch()
+	defer wb.Close()
+	for i := range keys {
+		keys[i] = []byte(fmt.Sprintf("key%03d", i))
+		wb.Put(keys[i], keys[i])
+	}
+	if err := db.WriteContext(context.Background(), wo, wb); err != nil {
+		t.Fatalf("write batch failed, err %v\n", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if data, err := db.GetContext(ctx, ro, keys[1]); err != nil || !bytes.Equal(data, keys[1]) {
+		t.Errorf("key:%s should be in the db, but the result is %s (%v)", keys[1], data, err)
+	}
+	values, errs := db.MultiGetContext(ctx, ro, keys)
+	for i := range keys {
+		if errs[i] != nil || !bytes.Equal(values[i], keys[i]) {
+			t.Fatalf("key:%s should be in the db, but the result is %s (%v)", keys[i], values[i], errs[i])
+		}
+	}
+	// The deadline is set on a copy of ro, which keeps its upper bound.
+	n := 0
+	err := db.ScanContext(ctx, ro, nil, nil, func(key, value []byte) error {
+		n++
+		return nil
+	})
+	if err != nil || n != 500 {
+		t.Errorf("the scan should return the 500 keys below the upper bound, but returned %d (%v)", n, err)
+	}
+
+	// Canceling the context in the middle stops the scan after the batch.
+	n = 0
+	err = db.ScanContext(ctx, ro, nil, nil, func(key, value []byte) error {
+		if n++; n == 1 {
+			cancel()
+		}
+		return nil
+	})
+	if err != context.Canceled || n == 500 {
+		t.Errorf("the scan should stop with context.Canceled, but returned %d keys (%v)", n, err)
+	}
+	if _, err := db.GetContext(ctx, ro, keys[1]); err != context.Canceled {
+		t.Errorf("get should fail with context.Canceled, got %v", err)
+	}
+	_, errs = db.MultiGetContext(ctx, ro, keys)
+	if len(errs) != len(keys) || errs[len(keys)-1] != context.Canceled {
+		t.Errorf("multiget should fail with context.Canceled, got %v", errs[len(errs)-1])
+	}
+	if err := db.WriteContext(ctx, wo, wb); err != context.Canceled {
+		t.Errorf("write should fail with context.Canceled, got %v", err)
+	}
+}
+
+func TestConcurrencyLimits(t *testing.T) {
+	db, wo, ro := openTestDB(t, "testdb_concurrency")
+
+	db.SetConcurrencyLimits(2, 1)
+	if reads, writes := db.ConcurrencyStats(); reads.Limit != 2 || writes.Limit != 1 {
+		t.Fatalf("the limits should be 2 and 1, but are %d and %d", reads.Limit, writes.Limit)
+	}
+
+	// Take the only write slot, so that the Put has to wait for it.
+	l := db.enterWrite()
+	done := make(chan error)
+	go func() {
+		done <- db.Put(wo, []byte("key1"), []byte("value1"))
+	}()
+	for {
+		if _, writes := db.ConcurrencyStats(); writes.Waiting == 1 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if data, err := db.Get(ro, []byte("key1")); err != nil || data != nil {
+		t.Errorf("key:key1 shouldn't be written while the put waits, but the result is %s (%v)", data, err)
+	}
+	l.leave()
+	if err := <-done; err != nil {
+		t.Fatalf("put key:key1 failed, err %v\n", err)
+	}
+
+	reads, writes := db.ConcurrencyStats()
+	if writes.Waits != 1 || writes.Waiting != 0 || writes.InFlight != 0 || writes.WaitTime <= 0 {
+		t.Errorf("the put should have waited once, but the write stats are %+v", writes)
+	}
+	if reads.Waits != 0 || reads.InFlight != 0 {
+		t.Errorf("the get shouldn't have waited, but the read stats are %+v", reads)
+	}
+
+	db.SetConcurrencyLimits(0, 0)
+	if reads, writes := db.ConcurrencyStats(); reads != (ConcurrencyStats{}) || writes != (ConcurrencyStats{}) {
+		t.Errorf("without limits the stats should be zero, but are %+v and %+v", reads, writes)
+	}
+}
+
+func TestAsyncWriter(t *testing.T) {
+	db, wo, ro := openTestDB(t, "testdb_async_writer")
+
+	w := NewAsyncWriter(db, wo, AsyncWriterOptions{MaxBatchCount: 8, MaxDelay: time.Second})
+	defer w.Close()
+
+	// The writes made while the first batch waits for more go into it, and
+	// the batch is committed when it is full.
+	futures := make([]*WriteFuture, 20)
+	for i := range futures {
+		futures[i] = w.Put([]byte(fmt.Sprintf("key%02d", i)), []byte(fmt.Sprintf("value%02d", i)))
+	}
+	for i, f := range futures[:8] {
+		if err := f.Wait(); err != nil {
+			t.Fatalf("put %d failed: %v", i, err)
+		}
+		if f.g != futures[0].g {
+			t.Errorf("put %d should be in the first batch", i)
+		}
+	}
+	if futures[8].g == futures[0].g || futures[16].g == futures[8].g {
+		t.Errorf("the batches should have at most 8 writes")
+	}
+	select {
+	case <-futures[16].Done():
+		t.Errorf("the last batch shouldn't be committed before it is full or flushed")
+	default:
+	}
+
+	if err := w.Flush(); err != nil {
+		t.Fatalf("flush failed: %v", err)
+	}
+	for i, f := range futures {
+		if err := f.Wait(); err != nil {
+			t.Fatalf("put %d failed: %v", i, err)
+		}
+		key := fmt.Sprintf("key%02d", i)
+		if data, err := db.Get(ro, []byte(key)); err != nil || string(data) != fmt.Sprintf("value%02d", i) {
+			t.Errorf("key:%s has the wrong value %s (%v)", key, data, err)
+		}
+	}
+
+	del := w.Delete([]byte("key00"))
+	if err := w.Close(); err != nil {
+		t.Fatalf("close failed: %v", err)
+	}
+	if err := del.Wait(); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+	if data, err := db.Get(ro, []byte("key00")); err != nil || data != nil {
+		t.Errorf("key:key00 should be deleted, but the result is %s (%v)", data, err)
+	}
+	if err := w.Close(); err != nil {
+		t.Errorf("closing the writer again should do nothing, but returned %v", err)
+	}
+	if err := w.Put([]byte("key"), []byte("value")).Wait(); err != ErrClosed {
+		t.Errorf("a put after close should fail with ErrClosed, but returned %v", err)
 	}
 }
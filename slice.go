@@ -0,0 +1,112 @@
+package ratgo
+
+// #cgo LDFLAGS: -lrocksdb -lrt
+// #include <stdlib.h>
+// #include "rocksdb/c.h"
+import "C"
+
+import (
+	"unsafe"
+)
+
+// Slice is a reference to bytes owned by RocksDB, which lets hot paths read
+// keys and values without copying them into Go memory. It is returned by
+// DB.GetSlice, Iterator.KeySlice and Iterator.ValueSlice.
+//
+// The bytes returned by Data are only valid until Free is called, and must
+// not be modified. Free must be called on every Slice when it is no longer
+// needed; the methods of a nil Slice, which DB.GetSlice returns for missing
+// keys, are safe to call.
+type Slice struct {
+	data *C.char
+	size C.size_t
+	// pinned holds the value of DB.GetSlice in place until Free.
+	pinned *C.leveldb_pinnableslice_t
+}
+
+// Data returns the bytes of the Slice, without copying them.
+func (s *Slice) Data() []byte {
+	if s == nil {
+		return nil
+	}
+	return charToBytes(s.data, s.size)
+}
+
+// Exists reports whether the Slice refers to a value, which it doesn't if
+// DB.GetSlice didn't find the key.
+func (s *Slice) Exists() bool {
+	return s != nil
+}
+
+// Free releases the bytes of the Slice.
+func (s *Slice) Free() {
+	if s == nil {
+		return
+	}
+	if s.pinned != nil {
+		C.leveldb_pinnableslice_destroy(s.pinned)
+		s.pinned = nil
+	}
+	s.data = nil
+	s.size = 0
+}
+
+// GetSlice returns the data associated with the key like DB.Get, but pins it
+// in RocksDB's memory rather than copying it. It returns a nil Slice if the
+// key doesn't exist.
+//
+// The DB must not be closed before the Slice is freed.
+func (db *DB) GetSlice(ro *ReadOptions, key []byte) (*Slice, error) {
+	if err := db.beginRead(ro); err != nil {
+		return nil, err
+	}
+	defer db.end()
+	var errStr *C.char
+	var k *C.char
+	if len(key) != 0 {
+		k = (*C.char)(unsafe.Pointer(&key[0]))
+	}
+
+	defer db.enterRead().leave()
+	pinned := C.leveldb_get_pinned(db.RocksDb, ro.Opt, k, C.size_t(len(key)), &errStr)
+	if errStr != nil {
+		return nil, newError(errStr)
+	}
+
+	if pinned == nil {
+		return nil, nil
+	}
+
+	s := &Slice{pinned: pinned}
+	s.data = C.leveldb_pinnableslice_value(pinned, &s.size)
+	return s, nil
+}
+
+// KeySlice returns the key the iterator currently holds, like Iterator.Key,
+// without copying it. The Slice is only valid until the iterator is moved
+// or closed; Free does nothing but may be called for symmetry.
+//
+// If Valid returns false, this method will panic.
+func (it *Iterator) KeySlice() *Slice {
+	s := &Slice{}
+	if !it.begin() {
+		return s
+	}
+	defer it.end()
+	s.data = C.leveldb_iter_key(it.Iter, &s.size)
+	return s
+}
+
+// ValueSlice returns the value the iterator currently holds, like
+// Iterator.Value, without copying it.
+//
+// See Iterator.KeySlice for details.
+func (it *Iterator) ValueSlice() *Slice {
+	s := &Slice{}
+	if !it.begin() {
+		return s
+	}
+	defer it.end()
+	s.data = C.leveldb_iter_value(it.Iter, &s.size)
+	return s
+}
@@ -0,0 +1,153 @@
+package ratgo
+
+// #cgo LDFLAGS: -lrocksdb -lrt
+// #include <stdint.h>
+// #include <stdlib.h>
+// #include "rocksdb/c.h"
+//
+// extern leveldb_slicetransform_t* ratgo_slicetransform_create(uintptr_t handle);
+import "C"
+
+import (
+	"strconv"
+	"unsafe"
+)
+
+// SliceTransform extracts the prefix of keys, which RocksDB uses to build
+// prefix bloom filters and to limit iterators to the keys with the same
+// prefix. It is set with Options.SetPrefixExtractor.
+//
+// Transform returns the prefix of a key for which InDomain returned true.
+// The prefix must be a subslice of the key passed in, such as key[:n], since
+// RocksDB keeps referring to the key. Otherwise, the callback panics with a
+// message that names the transform. InRange reports whether a slice can be the
+// prefix of a key.
+//
+// Name identifies the transform. If it changes in an incompatible way, the
+// name must change too, otherwise the filters built with the old transform
+// are used with the new one.
+//
+// See Callbacks in the package documentation for the rules the methods
+// follow.
+type SliceTransform interface {
+	Transform(key []byte) []byte
+	InDomain(key []byte) bool
+	InRange(prefix []byte) bool
+	Name() string
+}
+
+// NewFixedPrefixTransform returns a SliceTransform whose prefixes are the
+// first prefixLen bytes of a key. Shorter keys have no prefix.
+func NewFixedPrefixTransform(prefixLen int) SliceTransform {
+	return fixedPrefixTransform(prefixLen)
+}
+
+// NewCappedPrefixTransform returns a SliceTransform whose prefixes are the
+// first capLen bytes of a key, or the whole key if it is shorter.
+func NewCappedPrefixTransform(capLen int) SliceTransform {
+	return cappedPrefixTransform(capLen)
+}
+
+// fixedPrefixTransform and cappedPrefixTransform are replaced by the
+// transforms built into RocksDB when they are set on an Options, so the Go
+// methods are only used when they are called from Go.
+type fixedPrefixTransform int
+
+func (n fixedPrefixTransform) Transform(key []byte) []byte {
+	return key[:n]
+}
+
+func (n fixedPrefixTransform) InDomain(key []byte) bool {
+	return len(key) >= int(n)
+}
+
+func (n fixedPrefixTransform) InRange(prefix []byte) bool {
+	return len(prefix) == int(n)
+}
+
+func (n fixedPrefixTransform) Name() string {
+	return "rocksdb.FixedPrefix." + strconv.Itoa(int(n))
+}
+
+type cappedPrefixTransform int
+
+func (n cappedPrefixTransform) Transform(key []byte) []byte {
+	if len(key) > int(n) {
+		return key[:n]
+	}
+	return key
+}
+
+func (n cappedPrefixTransform) InDomain(key []byte) bool {
+	return true
+}
+
+func (n cappedPrefixTransform) InRange(prefix []byte) bool {
+	return len(prefix) <= int(n)
+}
+
+func (n cappedPrefixTransform) Name() string {
+	return "rocksdb.CappedPrefix." + strconv.Itoa(int(n))
+}
+
+// sliceTransformState is a SliceTransform with its name in C memory.
+type sliceTransformState struct {
+	st   SliceTransform
+	name *C.char
+}
+
+// newSliceTransform wraps st in a C leveldb_slicetransform_t. The transforms
+// built into RocksDB are used directly, every other one calls back into Go
+// and is released when the C slice transform is destroyed.
+func newSliceTransform(st SliceTransform) *C.leveldb_slicetransform_t {
+	switch st := st.(type) {
+	case fixedPrefixTransform:
+		return C.leveldb_slicetransform_create_fixed_prefix(C.size_t(st))
+	case cappedPrefixTransform:
+		return C.leveldb_slicetransform_create_capped_prefix(C.size_t(st))
+	}
+	h := newHandle(&sliceTransformState{st, C.CString(st.Name())})
+	return C.ratgo_slicetransform_create(C.uintptr_t(h))
+}
+
+//export ratgo_slicetransform_transform
+func ratgo_slicetransform_transform(h C.uintptr_t, key *C.char, length C.size_t, dstLength *C.size_t) *C.char {
+	state := handleValue(uintptr(h)).(*sliceTransformState)
+	k := charToBytes(key, length)
+	prefix := state.st.Transform(k)
+	*dstLength = C.size_t(len(prefix))
+	if len(prefix) == 0 {
+		return key
+	}
+	// The prefix has to point into the key, since C doesn't free it.
+	start := uintptr(unsafe.Pointer(key))
+	p := uintptr(unsafe.Pointer(&prefix[0]))
+	if p < start || p+uintptr(len(prefix)) > start+uintptr(length) {
+		panic("ratgo: the Transform of SliceTransform " + state.st.Name() + " returned a prefix that isn't a subslice of the key")
+	}
+	return (*C.char)(unsafe.Pointer(&prefix[0]))
+}
+
+//export ratgo_slicetransform_in_domain
+func ratgo_slicetransform_in_domain(h C.uintptr_t, key *C.char, length C.size_t) C.uchar {
+	state := handleValue(uintptr(h)).(*sliceTransformState)
+	return boolToUchar(state.st.InDomain(charToBytes(key, length)))
+}
+
+//export ratgo_slicetransform_in_range
+func ratgo_slicetransform_in_range(h C.uintptr_t, key *C.char, length C.size_t) C.uchar {
+	state := handleValue(uintptr(h)).(*sliceTransformState)
+	return boolToUchar(state.st.InRange(charToBytes(key, length)))
+}
+
+//export ratgo_slicetransform_name
+func ratgo_slicetransform_name(h C.uintptr_t) *C.char {
+	return handleValue(uintptr(h)).(*sliceTransformState).name
+}
+
+//export ratgo_slicetransform_destroy
+func ratgo_slicetransform_destroy(h C.uintptr_t) {
+	state := handleValue(uintptr(h)).(*sliceTransformState)
+	C.free(unsafe.Pointer(state.name))
+	deleteHandle(uintptr(h))
+}
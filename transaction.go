@@ -0,0 +1,400 @@
+package ratgo
+
+// #cgo LDFLAGS: -lrocksdb -lrt
+// #include <stdlib.h>
+// #include "rocksdb/c.h"
+import "C"
+
+import (
+	"unsafe"
+)
+
+// ConflictError is returned by the methods of a Transaction when the
+// transaction conflicts with another write to the same key, so that it
+// can't be committed. The transaction should be rolled back and retried.
+//
+// It wraps the *Error RocksDB reported the conflict with, whose Code is
+// CodeBusy or CodeTryAgain, so it matches ErrBusy or ErrTryAgain with
+// errors.Is.
+type ConflictError struct {
+	Err *Error
+}
+
+func (e *ConflictError) Error() string {
+	return e.Err.Msg
+}
+
+func (e *ConflictError) Unwrap() error {
+	return e.Err
+}
+
+// LockTimeoutError is returned by the methods of a Transaction of a
+// TransactionDB that give up waiting for a lock held by another
+// transaction. It wraps the *Error RocksDB reported, so it matches
+// ErrTimedOut and ErrLockTimeout with errors.Is.
+type LockTimeoutError struct {
+	Err *Error
+}
+
+func (e *LockTimeoutError) Error() string {
+	return e.Err.Msg
+}
+
+func (e *LockTimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// error turns an error of the transaction into an error of the type that
+// fits its code.
+func (t *Transaction) error(e *Error) error {
+	switch {
+	case e.Code == CodeBusy && e.SubCode == SubCodeDeadlock:
+		return t.db.deadlockError(e.Msg, t.ID())
+	case e.Code == CodeTimedOut && e.SubCode == SubCodeLockTimeout:
+		return &LockTimeoutError{Err: e}
+	case e.Code == CodeBusy && e.SubCode == SubCodeNone, e.Code == CodeTryAgain:
+		return &ConflictError{Err: e}
+	}
+	return e
+}
+
+// OptimisticTransactionDB is a database whose transactions don't lock the
+// keys they write, but check for conflicts when they are committed. This
+// is cheap when transactions rarely touch the same keys, but makes Commit
+// fail when they do. It is created by OpenOptimisticTransactionDB.
+//
+// The methods of the embedded DB write and read outside of transactions.
+type OptimisticTransactionDB struct {
+	*DB
+}
+
+// OpenOptimisticTransactionDB opens a database for optimistic transactions.
+func OpenOptimisticTransactionDB(dbName string, o *Options) (*OptimisticTransactionDB, error) {
+	var errStr *C.char
+	rocksDbName := C.CString(dbName)
+	defer C.free(unsafe.Pointer(rocksDbName))
+
+	rocksdb := C.leveldb_optimistictransactiondb_open(o.Opt, rocksDbName, &errStr)
+	if errStr != nil {
+		return nil, newError(errStr)
+	}
+	return &OptimisticTransactionDB{&DB{RocksDb: rocksdb, name: dbName}}, nil
+}
+
+// BeginTransaction begins a transaction that is written with the
+// WriteOptions given when it is committed.
+//
+// Commit fails with a ConflictError if a key the transaction wrote or read
+// with GetForUpdate was written by someone else since the transaction
+// began.
+func (db *OptimisticTransactionDB) BeginTransaction(wo *WriteOptions) *Transaction {
+	if err := db.beginWrite("BeginTransaction", wo); err != nil {
+		return &Transaction{db: db.DB}
+	}
+	defer db.end()
+	txn := C.leveldb_optimistictransaction_begin(db.RocksDb, wo.Opt, boolToUchar(true))
+	return newTransaction(db.DB, txn, callSite(1))
+}
+
+// Transaction is a set of reads and writes that are committed atomically
+// and in isolation from other transactions. Its writes are only visible to
+// the transaction itself until it is committed.
+//
+// A Transaction must not be used by several goroutines at once. To prevent
+// memory leaks, Close must be called on it once it is committed or rolled
+// back. The methods of a Transaction return ErrClosed once it or its DB is
+// closed, or do nothing if they don't return an error.
+type Transaction struct {
+	txn      *C.leveldb_transaction_t
+	db       *DB
+	snapshot *Snapshot
+	child    *child
+	// iterators are the children of the Iterators of the transaction, which
+	// are released with it.
+	iterators []*child
+}
+
+// newTransaction returns the Transaction txn of db, tracked as a child of db,
+// created at createdAt.
+func newTransaction(db *DB, txn *C.leveldb_transaction_t, createdAt string) *Transaction {
+	t := &Transaction{txn: txn, db: db}
+	if snap := C.leveldb_transaction_get_snapshot(txn); snap != nil {
+		t.snapshot = &Snapshot{snap: snap}
+	}
+	t.child = db.trackAt("Transaction", createdAt, t.release)
+	return t
+}
+
+// begin is DB.begin for a call into the transaction, which also returns
+// ErrClosed if the transaction is closed.
+func (t *Transaction) begin() error {
+	if err := t.db.begin(); err != nil {
+		return err
+	}
+	if t.txn == nil {
+		t.db.end()
+		return ErrClosed
+	}
+	return nil
+}
+
+// beginRead is begin for a read with ro.
+func (t *Transaction) beginRead(ro *ReadOptions) error {
+	if err := t.db.beginRead(ro); err != nil {
+		return err
+	}
+	if t.txn == nil {
+		t.db.end()
+		return ErrClosed
+	}
+	return nil
+}
+
+// end ends a call that began with begin or beginRead.
+func (t *Transaction) end() {
+	t.db.end()
+}
+
+// Snapshot returns the snapshot the transaction began with. Reads see the
+// latest data unless it is set on their ReadOptions. The snapshot belongs to
+// the transaction and must not be released with DB.ReleaseSnapshot.
+func (t *Transaction) Snapshot() *Snapshot {
+	return t.snapshot
+}
+
+// Get returns the data associated with the key, including the writes of the
+// transaction itself.
+//
+// See DB.Get for details.
+func (t *Transaction) Get(ro *ReadOptions, key []byte) ([]byte, error) {
+	if err := t.beginRead(ro); err != nil {
+		return nil, err
+	}
+	defer t.end()
+	var errStr *C.char
+	var vallen C.size_t
+	var k *C.char
+	if len(key) != 0 {
+		k = (*C.char)(unsafe.Pointer(&key[0]))
+	}
+
+	value := C.leveldb_transaction_get(t.txn, ro.Opt, k, C.size_t(len(key)), &vallen, &errStr)
+	if errStr != nil {
+		return nil, t.error(newError(errStr))
+	}
+
+	if value == nil {
+		return nil, nil
+	}
+
+	defer C.leveldb_free(unsafe.Pointer(value))
+	return C.GoBytes(unsafe.Pointer(value), C.int(vallen)), nil
+}
+
+// GetForUpdate returns the data associated with the key like Get, and makes
+// the transaction conflict with any other write to the key. In a
+// TransactionDB, the key is locked until the transaction ends.
+func (t *Transaction) GetForUpdate(ro *ReadOptions, key []byte) ([]byte, error) {
+	if err := t.beginRead(ro); err != nil {
+		return nil, err
+	}
+	defer t.end()
+	var errStr *C.char
+	var vallen C.size_t
+	var k *C.char
+	if len(key) != 0 {
+		k = (*C.char)(unsafe.Pointer(&key[0]))
+	}
+
+	value := C.leveldb_transaction_get_for_update(t.txn, ro.Opt,
+		k, C.size_t(len(key)), &vallen, boolToUchar(true), &errStr)
+	if errStr != nil {
+		return nil, t.error(newError(errStr))
+	}
+
+	if value == nil {
+		return nil, nil
+	}
+
+	defer C.leveldb_free(unsafe.Pointer(value))
+	return C.GoBytes(unsafe.Pointer(value), C.int(vallen)), nil
+}
+
+// Put writes data associated with a key when the transaction is committed.
+// In a TransactionDB, the key is locked until the transaction ends, like the
+// keys of Delete and Merge.
+//
+// See DB.Put for details.
+func (t *Transaction) Put(key, value []byte) error {
+	if err := t.begin(); err != nil {
+		return err
+	}
+	defer t.end()
+	var errStr *C.char
+	var k, v *C.char
+	if len(key) != 0 {
+		k = (*C.char)(unsafe.Pointer(&key[0]))
+	}
+	if len(value) != 0 {
+		v = (*C.char)(unsafe.Pointer(&value[0]))
+	}
+
+	C.leveldb_transaction_put(t.txn, k, C.size_t(len(key)), v, C.size_t(len(value)), &errStr)
+	if errStr != nil {
+		return t.error(newError(errStr))
+	}
+	return nil
+}
+
+// Delete removes the data associated with the key when the transaction is
+// committed.
+//
+// See DB.Delete for details.
+func (t *Transaction) Delete(key []byte) error {
+	if err := t.begin(); err != nil {
+		return err
+	}
+	defer t.end()
+	var errStr *C.char
+	var k *C.char
+	if len(key) != 0 {
+		k = (*C.char)(unsafe.Pointer(&key[0]))
+	}
+
+	C.leveldb_transaction_delete(t.txn, k, C.size_t(len(key)), &errStr)
+	if errStr != nil {
+		return t.error(newError(errStr))
+	}
+	return nil
+}
+
+// Merge merges value into the data associated with the key when the
+// transaction is committed.
+//
+// See DB.Merge for details.
+func (t *Transaction) Merge(key, value []byte) error {
+	if err := t.begin(); err != nil {
+		return err
+	}
+	defer t.end()
+	var errStr *C.char
+	var k, v *C.char
+	if len(key) != 0 {
+		k = (*C.char)(unsafe.Pointer(&key[0]))
+	}
+	if len(value) != 0 {
+		v = (*C.char)(unsafe.Pointer(&value[0]))
+	}
+
+	C.leveldb_transaction_merge(t.txn, k, C.size_t(len(key)), v, C.size_t(len(value)), &errStr)
+	if errStr != nil {
+		return t.error(newError(errStr))
+	}
+	return nil
+}
+
+// NewIterator returns an Iterator over the database with the writes of the
+// transaction applied. Closing the transaction releases the Iterator too,
+// which is then invalid and whose GetError returns ErrClosed.
+//
+// See DB.NewIterator for details.
+func (t *Transaction) NewIterator(ro *ReadOptions) *Iterator {
+	if err := t.beginRead(ro); err != nil {
+		return &Iterator{}
+	}
+	defer t.end()
+	it := &Iterator{Iter: C.leveldb_transaction_create_iterator(t.txn, ro.Opt)}
+	it.leak = trackLeak(it, "Iterator")
+	it.child = t.db.track("Iterator", it.releaser())
+
+	open := t.iterators[:0]
+	for _, c := range t.iterators {
+		if !c.released.Load() {
+			open = append(open, c)
+		}
+	}
+	t.iterators = append(open, it.child)
+	return it
+}
+
+// Commit atomically writes the writes of the transaction to the database.
+// If the transaction conflicts with another write, a ConflictError is
+// returned and nothing is written.
+func (t *Transaction) Commit() error {
+	if err := t.begin(); err != nil {
+		return err
+	}
+	defer t.end()
+	var errStr *C.char
+	C.leveldb_transaction_commit(t.txn, &errStr)
+	if errStr != nil {
+		return t.error(newError(errStr))
+	}
+	return nil
+}
+
+// SetSavePoint records the state of the transaction, which
+// RollbackToSavePoint returns to. Save points nest: every call to
+// RollbackToSavePoint undoes the writes since the most recent save point
+// that wasn't rolled back to yet.
+func (t *Transaction) SetSavePoint() {
+	if t.begin() != nil {
+		return
+	}
+	defer t.end()
+	C.leveldb_transaction_set_savepoint(t.txn)
+}
+
+// RollbackToSavePoint discards the writes of the transaction since the most
+// recent call to SetSavePoint, and removes the save point. It returns an
+// error if there is no save point.
+func (t *Transaction) RollbackToSavePoint() error {
+	if err := t.begin(); err != nil {
+		return err
+	}
+	defer t.end()
+	var errStr *C.char
+	C.leveldb_transaction_rollback_to_savepoint(t.txn, &errStr)
+	if errStr != nil {
+		return t.error(newError(errStr))
+	}
+	return nil
+}
+
+// Rollback discards the writes of the transaction.
+func (t *Transaction) Rollback() error {
+	if err := t.begin(); err != nil {
+		return err
+	}
+	defer t.end()
+	var errStr *C.char
+	C.leveldb_transaction_rollback(t.txn, &errStr)
+	if errStr != nil {
+		return t.error(newError(errStr))
+	}
+	return nil
+}
+
+// Close releases the transaction and its Iterators that are still open. A
+// transaction that is neither committed nor rolled back is rolled back.
+// Closing it again does nothing.
+func (t *Transaction) Close() {
+	if t.child != nil {
+		t.child.close()
+	}
+}
+
+// release frees the Iterators of the transaction, the C transaction and its
+// snapshot. It is called with db.mu held, or once shutdown released the
+// Iterators already.
+func (t *Transaction) release() {
+	for _, c := range t.iterators {
+		c.closeLocked()
+	}
+	t.iterators = nil
+	if t.snapshot != nil && !t.snapshot.released.Swap(true) {
+		C.leveldb_transaction_snapshot_destroy(t.snapshot.snap)
+	}
+	C.leveldb_transaction_destroy(t.txn)
+	t.txn = nil
+}
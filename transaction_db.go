@@ -0,0 +1,208 @@
+package ratgo
+
+// #cgo LDFLAGS: -lrocksdb -lrt
+// #include <stdlib.h>
+// #include "rocksdb/c.h"
+import "C"
+
+import (
+	"fmt"
+	"strings"
+	"unsafe"
+)
+
+// TransactionDB is a database whose transactions lock the keys they write or
+// read for update, so that conflicting transactions wait for each other
+// rather than failing when they are committed. It is created by
+// OpenTransactionDB.
+//
+// The methods of the embedded DB write and read outside of transactions;
+// their writes wait for the locks of the transactions too.
+type TransactionDB struct {
+	*DB
+}
+
+// DeadlockError is returned by the methods of a Transaction that would
+// deadlock waiting for a lock. The transaction should be rolled back and
+// retried. It matches ErrDeadlock with errors.Is.
+type DeadlockError struct {
+	Msg string
+	// Cycle are the transactions that wait for each other: each one waits
+	// for a key held by the next one, and the last one for a key held by the
+	// first. It is empty if the deadlock couldn't be recorded, or another
+	// deadlock was recorded since.
+	Cycle []DeadlockInfo
+}
+
+// DeadlockInfo is a transaction that is part of a deadlock.
+type DeadlockInfo struct {
+	TransactionID  uint64
+	ColumnFamilyID uint32
+	// WaitingKey is the key the transaction waits for.
+	WaitingKey []byte
+	// Exclusive is set if the transaction waits for an exclusive lock.
+	Exclusive bool
+}
+
+func (e *DeadlockError) Error() string {
+	if len(e.Cycle) == 0 {
+		return e.Msg
+	}
+	cycle := make([]string, len(e.Cycle))
+	for i, info := range e.Cycle {
+		cycle[i] = fmt.Sprintf("transaction %d waits for %q", info.TransactionID, info.WaitingKey)
+	}
+	return e.Msg + " (" + strings.Join(cycle, ", ") + ")"
+}
+
+func (e *DeadlockError) Is(target error) bool {
+	return target == ErrBusy || target == ErrDeadlock
+}
+
+// OpenTransactionDB opens a database for pessimistic transactions.
+//
+// Transactions that were prepared with Transaction.Prepare but neither
+// committed nor rolled back before the database was closed are recovered,
+// and are returned by GetPreparedTransactions.
+func OpenTransactionDB(dbName string, o *Options, txnDBOpts *TransactionDBOptions) (*TransactionDB, error) {
+	var errStr *C.char
+	rocksDbName := C.CString(dbName)
+	defer C.free(unsafe.Pointer(rocksDbName))
+
+	rocksdb := C.leveldb_transactiondb_open(o.Opt, txnDBOpts.Opt, rocksDbName, &errStr)
+	if errStr != nil {
+		return nil, newError(errStr)
+	}
+	return &TransactionDB{&DB{RocksDb: rocksdb, name: dbName}}, nil
+}
+
+// BeginTransaction begins a transaction that is written with the
+// WriteOptions given when it is committed.
+func (db *TransactionDB) BeginTransaction(wo *WriteOptions, to *TransactionOptions) *Transaction {
+	if err := db.beginWrite("BeginTransaction", wo); err != nil {
+		return &Transaction{db: db.DB}
+	}
+	defer db.end()
+	txn := C.leveldb_transaction_begin(db.RocksDb, wo.Opt, to.Opt)
+	return newTransaction(db.DB, txn, callSite(1))
+}
+
+// GetPreparedTransactions returns the transactions that were recovered when
+// the database was opened, in no particular order. They have to be
+// committed or rolled back, and closed.
+func (db *TransactionDB) GetPreparedTransactions() []*Transaction {
+	if db.begin() != nil {
+		return nil
+	}
+	defer db.end()
+	var num C.size_t
+	txns := C.leveldb_transactiondb_get_prepared_transactions(db.RocksDb, &num)
+	defer C.leveldb_free(unsafe.Pointer(txns))
+
+	createdAt := callSite(1)
+	prepared := make([]*Transaction, int(num))
+	for i, txn := range unsafe.Slice(txns, int(num)) {
+		prepared[i] = newTransaction(db.DB, txn, createdAt)
+	}
+	return prepared
+}
+
+// deadlockError returns a DeadlockError for the transaction id, with the
+// most recent deadlock the database detected if the transaction is part of
+// it. Another transaction may have deadlocked since, so it might not be.
+func (db *DB) deadlockError(msg string, id uint64) error {
+	var txnIDs *C.uint64_t
+	var cfIDs *C.uint32_t
+	var keys **C.char
+	var keyLens *C.size_t
+	var exclusive *C.uchar
+	num := int(C.leveldb_transactiondb_get_last_deadlock(db.RocksDb,
+		&txnIDs, &cfIDs, &keys, &keyLens, &exclusive))
+	e := &DeadlockError{Msg: msg}
+	if num == 0 {
+		return e
+	}
+	defer C.leveldb_free(unsafe.Pointer(txnIDs))
+	defer C.leveldb_free(unsafe.Pointer(cfIDs))
+	defer C.leveldb_free(unsafe.Pointer(keys))
+	defer C.leveldb_free(unsafe.Pointer(keyLens))
+	defer C.leveldb_free(unsafe.Pointer(exclusive))
+
+	keySlice := unsafe.Slice(keys, num)
+	keyLenSlice := unsafe.Slice(keyLens, num)
+	cfIDSlice := unsafe.Slice(cfIDs, num)
+	exclusiveSlice := unsafe.Slice(exclusive, num)
+	for i, id := range unsafe.Slice(txnIDs, num) {
+		e.Cycle = append(e.Cycle, DeadlockInfo{
+			TransactionID:  uint64(id),
+			ColumnFamilyID: uint32(cfIDSlice[i]),
+			WaitingKey:     C.GoBytes(unsafe.Pointer(keySlice[i]), C.int(keyLenSlice[i])),
+			Exclusive:      ucharToBool(exclusiveSlice[i]),
+		})
+		C.leveldb_free(unsafe.Pointer(keySlice[i]))
+	}
+	for _, info := range e.Cycle {
+		if info.TransactionID == id {
+			return e
+		}
+	}
+	return &DeadlockError{Msg: msg}
+}
+
+// ID returns the identifier of the transaction, as used by DeadlockInfo.
+func (t *Transaction) ID() uint64 {
+	if t.begin() != nil {
+		return 0
+	}
+	defer t.end()
+	return uint64(C.leveldb_transaction_get_id(t.txn))
+}
+
+// SetName names a transaction of a TransactionDB, which is required to
+// Prepare it. The name must be unique among the transactions of the
+// database.
+func (t *Transaction) SetName(name string) error {
+	if err := t.begin(); err != nil {
+		return err
+	}
+	defer t.end()
+	var errStr *C.char
+	cname := C.CString(name)
+	defer C.free(unsafe.Pointer(cname))
+
+	C.leveldb_transaction_set_name(t.txn, cname, C.size_t(len(name)), &errStr)
+	if errStr != nil {
+		return t.error(newError(errStr))
+	}
+	return nil
+}
+
+// Name returns the name of the transaction, or "" if it has none.
+func (t *Transaction) Name() string {
+	if t.begin() != nil {
+		return ""
+	}
+	defer t.end()
+	var namelen C.size_t
+	name := C.leveldb_transaction_get_name(t.txn, &namelen)
+	defer C.leveldb_free(unsafe.Pointer(name))
+	return C.GoStringN(name, C.int(namelen))
+}
+
+// Prepare is the first phase of a two-phase commit: it makes the writes of a
+// named transaction durable without committing them, so that Commit can't
+// fail anymore. If the database is closed before the transaction is
+// committed or rolled back, it is recovered when the database is opened
+// again. This requires Options.SetAllow2PC.
+func (t *Transaction) Prepare() error {
+	if err := t.begin(); err != nil {
+		return err
+	}
+	defer t.end()
+	var errStr *C.char
+	C.leveldb_transaction_prepare(t.txn, &errStr)
+	if errStr != nil {
+		return t.error(newError(errStr))
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+package ratgo
+
+// #cgo LDFLAGS: -lrocksdb -lrt
+// #include <stdlib.h>
+// #include "rocksdb/c.h"
+import "C"
+
+import (
+	"math"
+	"time"
+	"unsafe"
+)
+
+// OpenWithTTL opens a database whose values expire ttl after they were
+// written. A zero or negative ttl means that values never expire.
+//
+// Put, Merge and Write store the time of the write with every value and the
+// read methods strip it again, so a database must always be opened with
+// OpenWithTTL or OpenColumnFamiliesWithTTL once it was. Expired values are
+// dropped when they are compacted; until then, they are still returned by
+// the read methods. CompactRange can be used to drop them eagerly.
+func OpenWithTTL(dbName string, o *Options, ttl time.Duration) (*DB, error) {
+	var errStr *C.char
+	rocksDbName := C.CString(dbName)
+	defer C.free(unsafe.Pointer(rocksDbName))
+
+	rocksdb := C.leveldb_open_with_ttl(o.Opt, rocksDbName, ttlSeconds(ttl), &errStr)
+	if errStr != nil {
+		return nil, newError(errStr)
+	}
+	db := &DB{RocksDb: rocksdb, name: dbName, withTTL: true}
+	db.ttl.Store(int32(ttlSeconds(ttl)))
+	return db, nil
+}
+
+// OpenColumnFamiliesWithTTL opens a database with column families, like
+// OpenColumnFamilies, whose values expire like those of OpenWithTTL. The
+// values of cfNames[i] expire after ttls[i].
+func OpenColumnFamiliesWithTTL(dbName string, o *Options, cfNames []string, cfOpts []*Options, ttls []time.Duration) (*DB, []*ColumnFamilyHandle, error) {
+	if len(cfNames) != len(cfOpts) || len(cfNames) != len(ttls) {
+		return nil, nil, DatabaseError("ratgo: the number of column family names, options and ttls must be the same")
+	}
+	// One spare element, like the arrays of openColumnFamilies.
+	cTTLs := make([]C.int, len(ttls)+1)
+	for i, ttl := range ttls {
+		cTTLs[i] = ttlSeconds(ttl)
+	}
+	db, cfHandles, err := openColumnFamilies(dbName, cfNames, cfOpts, callSite(1),
+		func(name *C.char, num C.int, names **C.char, opts **C.leveldb_options_t, handles **C.leveldb_column_family_handle_t, errStr **C.char) *C.leveldb_t {
+			return C.leveldb_open_column_families_with_ttl(o.Opt, name, num, names, opts, &cTTLs[0], handles, errStr)
+		})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	db.withTTL = true
+	for i, cf := range cfHandles {
+		cf.ttl.Store(int32(cTTLs[i]))
+		if cf.name == DefaultColumnFamilyName {
+			db.ttl.Store(int32(cTTLs[i]))
+		}
+	}
+	return db, cfHandles, nil
+}
+
+// SetTTL changes the ttl of the default column family of a database opened
+// with OpenWithTTL. It applies to the values already written too, including
+// those written by PutWithTTL: their expiry moves by the difference between
+// the new and the old ttl.
+func (db *DB) SetTTL(ttl time.Duration) error {
+	if err := db.begin(); err != nil {
+		return err
+	}
+	defer db.end()
+	if !db.withTTL {
+		return DatabaseError("ratgo: SetTTL requires a database opened with a ttl")
+	}
+	C.leveldb_set_ttl(db.RocksDb, nil, ttlSeconds(ttl))
+	db.ttl.Store(int32(ttlSeconds(ttl)))
+	return nil
+}
+
+// SetTTLCF changes the ttl of a column family of a database opened with
+// OpenColumnFamiliesWithTTL.
+//
+// See DB.SetTTL for details.
+func (db *DB) SetTTLCF(cf *ColumnFamilyHandle, ttl time.Duration) error {
+	if err := db.begin(cf); err != nil {
+		return err
+	}
+	defer db.end()
+	if !db.withTTL {
+		return DatabaseError("ratgo: SetTTLCF requires a database opened with a ttl")
+	}
+	C.leveldb_set_ttl(db.RocksDb, cf.cf, ttlSeconds(ttl))
+	cf.ttl.Store(int32(ttlSeconds(ttl)))
+	if cf.name == DefaultColumnFamilyName {
+		db.ttl.Store(int32(ttlSeconds(ttl)))
+	}
+	return nil
+}
+
+// PutWithTTL writes a value that expires ttl from now instead of after the
+// ttl the database was opened with, which must be positive.
+//
+// RocksDB only stores the time of a write with the value, so the expiry is
+// kept as a time relative to the ttl of the database. A later SetTTL moves
+// it by the difference between the new and the old ttl, like that of the
+// other values.
+//
+// See DB.Put for details.
+func (db *DB) PutWithTTL(wo *WriteOptions, key, value []byte, ttl time.Duration) error {
+	if err := db.beginWrite("PutWithTTL", wo); err != nil {
+		return err
+	}
+	defer db.end()
+	return db.putWithTTL("PutWithTTL", wo, nil, db.ttl.Load(), key, value, ttl)
+}
+
+// PutCFWithTTL writes a value to a column family that expires ttl from now
+// instead of after the ttl of the column family, which must be positive. A
+// later SetTTLCF moves the expiry like SetTTL does for PutWithTTL.
+//
+// See DB.Put for details.
+func (db *DB) PutCFWithTTL(wo *WriteOptions, cf *ColumnFamilyHandle, key, value []byte, ttl time.Duration) error {
+	if err := db.beginWrite("PutCFWithTTL", wo, cf); err != nil {
+		return err
+	}
+	defer db.end()
+	return db.putWithTTL("PutCFWithTTL", wo, cf.cf, cf.ttl.Load(), key, value, ttl)
+}
+
+// putWithTTL writes a value with a ttl to the column family c, whose ttl in
+// seconds is cfTTL, or to the default column family if c is nil. The call
+// must have begun with beginWrite.
+func (db *DB) putWithTTL(method string, wo *WriteOptions, c *C.leveldb_column_family_handle_t, cfTTL int32, key, value []byte, ttl time.Duration) error {
+	if !db.withTTL || cfTTL <= 0 {
+		return DatabaseError("ratgo: " + method + " requires a column family with a positive ttl")
+	}
+	var errStr *C.char
+	var k, v *C.char
+	if len(key) != 0 {
+		k = (*C.char)(unsafe.Pointer(&key[0]))
+	}
+	if len(value) != 0 {
+		v = (*C.char)(unsafe.Pointer(&value[0]))
+	}
+
+	defer db.enterWrite().leave()
+	C.leveldb_put_with_ttl(db.RocksDb, wo.Opt, c,
+		k, C.size_t(len(key)), v, C.size_t(len(value)),
+		ttlSeconds(ttl), C.int(cfTTL), &errStr)
+	if errStr != nil {
+		return newError(errStr)
+	}
+	return nil
+}
+
+// ttlSeconds converts ttl to the whole seconds RocksDB expects, rounding up
+// so that short positive ttls don't turn into 0, which means no expiry.
+// Those that don't fit into an int, of about 68 years and more, are clamped
+// to the largest one.
+func ttlSeconds(ttl time.Duration) C.int {
+	if ttl <= 0 {
+		return 0
+	}
+	if ttl > math.MaxInt32*time.Second {
+		return math.MaxInt32
+	}
+	return C.int((ttl + time.Second - 1) / time.Second)
+}